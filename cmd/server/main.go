@@ -28,6 +28,14 @@ type Config struct {
 }
 
 func main() {
+	// Started by the node service to write the node log
+	if len(os.Args) == 3 && os.Args[1] == node.LogPumpCommand {
+		if err := node.RunLogPump(os.Args[2]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Load or create config
 	config := loadConfig()
 
@@ -40,6 +48,7 @@ func main() {
 	r := chi.NewRouter()
 
 	// Middleware
+	r.Use(auth.RedactToken)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(cors.Handler(cors.Options{
@@ -59,6 +68,7 @@ func main() {
 		r.Get("/auth/status", apiHandler.AuthStatus)
 		r.Get("/dependencies/status", apiHandler.GetDependenciesStatus)
 		r.Post("/dependencies/install", apiHandler.InstallDependencies)
+		r.Get("/dependencies/jobs/{id}", apiHandler.GetDependencyJob)
 
 		// Protected routes
 		r.Group(func(r chi.Router) {
@@ -71,7 +81,13 @@ func main() {
 			r.Post("/wallet/active", apiHandler.SetActiveWallet)
 			r.Get("/wallet/info", apiHandler.GetWalletInfo)
 			r.Post("/wallet/delete", apiHandler.DeleteWallet)
+			r.Post("/wallet/reencrypt", apiHandler.ReencryptWallets)
+			r.Post("/wallet/migrate-keyring", apiHandler.MigrateTestKeyring)
 			r.Get("/wallet/balance", apiHandler.GetBalance)
+			r.Post("/wallet/send", apiHandler.Send)
+			r.Get("/addressbook", apiHandler.GetAddressBook)
+			r.Post("/addressbook", apiHandler.SaveAddressBookEntry)
+			r.Post("/addressbook/delete", apiHandler.DeleteAddressBookEntry)
 
 			// Node
 			r.Get("/node/status", apiHandler.GetNodeStatus)
@@ -80,10 +96,31 @@ func main() {
 			r.Post("/node/start", apiHandler.StartNode)
 			r.Post("/node/stop", apiHandler.StopNode)
 			r.Get("/node/logs", apiHandler.GetLogs)
+			r.Get("/node/crashes", apiHandler.GetCrashHistory)
+			r.Get("/node/runtime", apiHandler.GetRuntimeConfig)
+			r.Post("/node/runtime", apiHandler.SaveRuntimeConfig)
 
 			// Cosmovisor
 			r.Post("/cosmovisor/install", apiHandler.InstallCosmovisor)
 			r.Post("/cosmovisor/setup", apiHandler.SetupCosmovisor)
+			r.Get("/upgrade", apiHandler.GetUpgradeStatus)
+			r.Post("/upgrade/prepare", apiHandler.PrepareUpgrade)
+
+			// Binary versions
+			r.Get("/versions", apiHandler.GetVersions)
+			r.Post("/versions/install", apiHandler.InstallVersion)
+			r.Post("/versions/activate", apiHandler.ActivateVersion)
+			r.Post("/versions/rollback", apiHandler.RollbackVersion)
+			r.Post("/versions/index", apiHandler.SetReleaseIndex)
+
+			// Download verification
+			r.Get("/verification", apiHandler.GetVerification)
+			r.Post("/verification", apiHandler.SaveVerification)
+
+			// Jobs
+			r.Get("/jobs", apiHandler.GetJobs)
+			r.Get("/jobs/{id}", apiHandler.GetJob)
+			r.Post("/jobs/{id}/cancel", apiHandler.CancelJob)
 
 			// Validator
 			r.Post("/validator/create", apiHandler.CreateValidator)
@@ -91,6 +128,40 @@ func main() {
 			r.Get("/validator/staking", apiHandler.GetStakingInfo)
 			r.Post("/validator/withdraw", apiHandler.WithdrawRewards)
 			r.Post("/validator/restake", apiHandler.Restake)
+			r.Post("/validator/edit", apiHandler.EditValidator)
+			r.Post("/validator/unjail", apiHandler.Unjail)
+
+			// Delegations
+			r.Get("/validators", apiHandler.GetValidators)
+			r.Get("/delegations", apiHandler.GetDelegations)
+			r.Post("/delegations/delegate", apiHandler.Delegate)
+			r.Post("/delegations/undelegate", apiHandler.Undelegate)
+			r.Post("/delegations/redelegate", apiHandler.Redelegate)
+
+			// Transactions
+			r.Get("/txs", apiHandler.GetTxHistory)
+			r.Post("/txs/import", apiHandler.ImportTxHistory)
+
+			// Governance
+			r.Get("/gov/proposals", apiHandler.GetProposals)
+			r.Get("/gov/proposals/{id}", apiHandler.GetProposal)
+			r.Post("/gov/proposals/{id}/vote", apiHandler.Vote)
+
+			// Auto-compound
+			r.Get("/autocompound", apiHandler.GetAutoCompound)
+			r.Post("/autocompound/config", apiHandler.SaveAutoCompoundConfig)
+			r.Post("/autocompound/authorize", apiHandler.AuthorizeAutoCompound)
+			r.Post("/autocompound/revoke", apiHandler.RevokeAutoCompound)
+			r.Post("/autocompound/run", apiHandler.RunAutoCompound)
+			r.Get("/autocompound/history", apiHandler.GetAutoCompoundHistory)
+		})
+
+		// Server-Sent Events, which may pass the token in the query
+		r.Group(func(r chi.Router) {
+			r.Use(authService.StreamMiddleware)
+
+			r.Get("/node/logs/stream", apiHandler.StreamLogs)
+			r.Get("/jobs/{id}/events", apiHandler.StreamJob)
 		})
 	})
 
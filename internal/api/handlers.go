@@ -2,8 +2,13 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/tickfy/tickfy-validator-setup/internal/auth"
 	"github.com/tickfy/tickfy-validator-setup/internal/node"
 )
@@ -42,6 +47,8 @@ func (h *Handler) GetDependenciesStatus(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// InstallDependencies starts the install as a job and returns it right
+// away; its progress is read from GetDependencyJob.
 func (h *Handler) InstallDependencies(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Component string `json:"component"` // "binary" or "cosmovisor"
@@ -50,28 +57,30 @@ func (h *Handler) InstallDependencies(w http.ResponseWriter, r *http.Request) {
 		h.respondError(w, http.StatusBadRequest, "JSON inválido")
 		return
 	}
-
-	switch req.Component {
-	case "binary":
-		if err := h.nodeService.InstallNode(nil); err != nil {
-			h.respondError(w, http.StatusInternalServerError, err.Error())
-			return
-		}
-	case "cosmovisor":
-		if err := h.nodeService.InstallCosmovisor(nil); err != nil {
-			h.respondError(w, http.StatusInternalServerError, err.Error())
-			return
-		}
-		if err := h.nodeService.SetupCosmovisorDirs(); err != nil {
-			h.respondError(w, http.StatusInternalServerError, err.Error())
-			return
-		}
-	default:
+	if req.Component != "binary" && req.Component != "cosmovisor" {
 		h.respondError(w, http.StatusBadRequest, "Componente inválido")
 		return
 	}
 
-	h.respondJSON(w, http.StatusOK, map[string]string{"message": "Instalado com sucesso"})
+	job, err := h.nodeService.StartDependencyJob(req.Component)
+	if err != nil {
+		h.respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusAccepted, job)
+}
+
+// GetDependencyJob is public like InstallDependencies, so it only shows
+// install jobs.
+func (h *Handler) GetDependencyJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.nodeService.GetJob(chi.URLParam(r, "id"))
+	if err != nil || (job.Kind != node.JobInstallNode && job.Kind != node.JobInstallCosmovisor) {
+		h.respondError(w, http.StatusNotFound, "tarefa não encontrada")
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, job)
 }
 
 // =============================================================================
@@ -133,6 +142,19 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 // WALLET
 // =============================================================================
 
+// walletErrorStatus maps the errors of resolving and unlocking a wallet to a
+// status code, or returns fallback for any other error.
+func walletErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, node.ErrNoWallet), errors.Is(err, node.ErrWalletNotFound), errors.Is(err, node.ErrValidatorNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, node.ErrWrongPassword):
+		return http.StatusUnauthorized
+	default:
+		return fallback
+	}
+}
+
 func (h *Handler) CreateWallet(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Name     string `json:"name"`
@@ -239,6 +261,47 @@ func (h *Handler) DeleteWallet(w http.ResponseWriter, r *http.Request) {
 	h.respondJSON(w, http.StatusOK, map[string]string{"message": "Carteira removida"})
 }
 
+func (h *Handler) ReencryptWallets(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "JSON inválido")
+		return
+	}
+
+	upgraded, current, failed, err := h.nodeService.ReencryptWallets(req.Password)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"upgraded": upgraded,
+		"current":  current,
+		"failed":   failed,
+	})
+}
+
+func (h *Handler) MigrateTestKeyring(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Password string `json:"password"`
+		WalletID string `json:"walletId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "JSON inválido")
+		return
+	}
+
+	result, err := h.nodeService.MigrateTestKeyring(req.WalletID, req.Password)
+	if err != nil {
+		h.respondError(w, walletErrorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, result)
+}
+
 func (h *Handler) GetBalance(w http.ResponseWriter, r *http.Request) {
 	address, _, err := h.nodeService.GetWalletInfo()
 	if err != nil {
@@ -255,6 +318,88 @@ func (h *Handler) GetBalance(w http.ResponseWriter, r *http.Request) {
 	h.respondJSON(w, http.StatusOK, balance)
 }
 
+// Send previews a transfer when no confirmationId is given, and executes the
+// previewed transfer when it is.
+func (h *Handler) Send(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		node.SendRequest
+		Password       string `json:"password"`
+		WalletID       string `json:"walletId"`
+		ConfirmationID string `json:"confirmationId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "JSON inválido")
+		return
+	}
+
+	if req.ConfirmationID == "" {
+		preview, err := h.nodeService.PrepareSend(req.Password, req.WalletID, req.SendRequest)
+		if err != nil {
+			h.respondError(w, walletErrorStatus(err, http.StatusBadRequest), err.Error())
+			return
+		}
+		h.respondJSON(w, http.StatusOK, map[string]interface{}{
+			"message":      "Confirme o envio",
+			"confirmation": preview,
+		})
+		return
+	}
+
+	result, err := h.nodeService.ConfirmSend(req.Password, req.ConfirmationID)
+	if err != nil {
+		status := walletErrorStatus(err, http.StatusBadRequest)
+		if errors.Is(err, node.ErrSendNotFound) {
+			status = http.StatusNotFound
+		}
+		h.respondError(w, status, err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Envio realizado",
+		"tx":      result,
+	})
+}
+
+func (h *Handler) GetAddressBook(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"entries": h.nodeService.GetAddressBook(),
+	})
+}
+
+func (h *Handler) SaveAddressBookEntry(w http.ResponseWriter, r *http.Request) {
+	var entry node.AddressBookEntry
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		h.respondError(w, http.StatusBadRequest, "JSON inválido")
+		return
+	}
+
+	saved, err := h.nodeService.SaveAddressBookEntry(entry)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, saved)
+}
+
+func (h *Handler) DeleteAddressBookEntry(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "JSON inválido")
+		return
+	}
+
+	if err := h.nodeService.DeleteAddressBookEntry(req.ID); err != nil {
+		h.respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]string{"message": "Endereço removido"})
+}
+
 // =============================================================================
 // NODE
 // =============================================================================
@@ -265,13 +410,13 @@ func (h *Handler) GetNodeStatus(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) InstallNode(w http.ResponseWriter, r *http.Request) {
-	// For simplicity, run synchronously (could use SSE for progress)
-	if err := h.nodeService.InstallNode(nil); err != nil {
-		h.respondError(w, http.StatusInternalServerError, err.Error())
+	job, err := h.nodeService.StartJob(node.JobInstallNode)
+	if err != nil {
+		h.respondError(w, http.StatusConflict, err.Error())
 		return
 	}
 
-	h.respondJSON(w, http.StatusOK, map[string]string{"message": "Node instalado com sucesso"})
+	h.respondJSON(w, http.StatusAccepted, job)
 }
 
 func (h *Handler) InitNode(w http.ResponseWriter, r *http.Request) {
@@ -311,12 +456,146 @@ func (h *Handler) StopNode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.respondJSON(w, http.StatusOK, map[string]string{"message": "Node parado"})
+	h.respondJSON(w, http.StatusAccepted, map[string]string{"message": "Parando node"})
 }
 
+func (h *Handler) GetCrashHistory(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{"crashes": h.nodeService.GetCrashHistory()})
+}
+
+// GetLogs pages backwards from the newest entry of the node log, each page
+// in chronological order. Filters: query (text), level (minimum), module,
+// since/until (RFC3339 or unix seconds), cursor (nextCursor of the previous
+// page) and limit.
 func (h *Handler) GetLogs(w http.ResponseWriter, r *http.Request) {
-	logs := h.nodeService.GetLogs(100)
-	h.respondJSON(w, http.StatusOK, map[string]interface{}{"logs": logs})
+	params := r.URL.Query()
+	q := node.LogQuery{
+		Query:  params.Get("query"),
+		Level:  params.Get("level"),
+		Module: params.Get("module"),
+	}
+
+	var err error
+	if q.Since, err = parseTimeParam(params.Get("since")); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Parâmetro since inválido")
+		return
+	}
+	if q.Until, err = parseTimeParam(params.Get("until")); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Parâmetro until inválido")
+		return
+	}
+	if v := params.Get("cursor"); v != "" {
+		if q.Cursor, err = strconv.ParseInt(v, 10, 64); err != nil {
+			h.respondError(w, http.StatusBadRequest, "Parâmetro cursor inválido")
+			return
+		}
+	}
+	if v := params.Get("limit"); v != "" {
+		q.Limit, _ = strconv.Atoi(v)
+	}
+
+	page := h.nodeService.QueryLogs(q)
+
+	// Plain lines for clients that only show text
+	logs := make([]string, len(page.Entries))
+	for i, entry := range page.Entries {
+		logs[i] = fmt.Sprintf("[%s] %s", entry.Time.Format("15:04:05"), entry.Raw)
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"logs":       logs,
+		"entries":    page.Entries,
+		"nextCursor": page.NextCursor,
+	})
+}
+
+// StreamLogs pushes new log entries as Server-Sent Events. It accepts the
+// same query, level and module filters as GetLogs. A client that reconnects
+// with Last-Event-ID (sent by EventSource automatically) or ?after=<id>
+// first gets the entries it missed.
+func (h *Handler) StreamLogs(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.respondError(w, http.StatusInternalServerError, "Streaming não suportado")
+		return
+	}
+
+	params := r.URL.Query()
+	filter := node.LogQuery{
+		Query:  params.Get("query"),
+		Level:  params.Get("level"),
+		Module: params.Get("module"),
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if v := params.Get("after"); v != "" {
+		lastID = v
+	}
+	var after int64
+	if lastID != "" {
+		var err error
+		if after, err = strconv.ParseInt(lastID, 10, 64); err != nil {
+			h.respondError(w, http.StatusBadRequest, "Parâmetro after inválido")
+			return
+		}
+	}
+
+	sub, replay, unsubscribe := h.nodeService.SubscribeLogs(filter, after)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, entry := range replay {
+		h.writeLogEvent(w, entry)
+		after = entry.ID
+	}
+	replayed := after
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case entry, ok := <-sub.Entries:
+			if !ok {
+				if sub.Lagged() {
+					h.writeEvent(w, "lagged", map[string]int64{"lastId": after})
+					flusher.Flush()
+				}
+				return
+			}
+			if entry.ID <= replayed {
+				continue // already sent by the replay
+			}
+			h.writeLogEvent(w, entry)
+			after = entry.ID
+			flusher.Flush()
+		}
+	}
+}
+
+func (h *Handler) writeLogEvent(w http.ResponseWriter, entry node.LogEntry) {
+	payload, _ := json.Marshal(entry)
+	fmt.Fprintf(w, "id: %d\nevent: log\ndata: %s\n\n", entry.ID, payload)
+}
+
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
 }
 
 // =============================================================================
@@ -324,12 +603,13 @@ func (h *Handler) GetLogs(w http.ResponseWriter, r *http.Request) {
 // =============================================================================
 
 func (h *Handler) InstallCosmovisor(w http.ResponseWriter, r *http.Request) {
-	if err := h.nodeService.InstallCosmovisor(nil); err != nil {
-		h.respondError(w, http.StatusInternalServerError, err.Error())
+	job, err := h.nodeService.StartJob(node.JobInstallCosmovisor)
+	if err != nil {
+		h.respondError(w, http.StatusConflict, err.Error())
 		return
 	}
 
-	h.respondJSON(w, http.StatusOK, map[string]string{"message": "Cosmovisor instalado com sucesso"})
+	h.respondJSON(w, http.StatusAccepted, job)
 }
 
 func (h *Handler) SetupCosmovisor(w http.ResponseWriter, r *http.Request) {
@@ -341,34 +621,111 @@ func (h *Handler) SetupCosmovisor(w http.ResponseWriter, r *http.Request) {
 	h.respondJSON(w, http.StatusOK, map[string]string{"message": "Cosmovisor configurado com sucesso"})
 }
 
+// =============================================================================
+// JOBS
+// =============================================================================
+
+func (h *Handler) GetJobs(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{"jobs": h.nodeService.GetJobs()})
+}
+
+func (h *Handler) GetJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.nodeService.GetJob(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, job)
+}
+
+func (h *Handler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	if err := h.nodeService.CancelJob(chi.URLParam(r, "id")); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]string{"message": "Tarefa cancelada"})
+}
+
+// StreamJob sends the job state followed by every progress, log and done
+// event as Server-Sent Events until the job finishes or the client leaves.
+func (h *Handler) StreamJob(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.respondError(w, http.StatusInternalServerError, "Streaming não suportado")
+		return
+	}
+
+	job, events, unsubscribe, err := h.nodeService.SubscribeJob(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	h.writeEvent(w, "snapshot", job)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				if job, err := h.nodeService.GetJob(job.ID); err == nil {
+					h.writeEvent(w, "result", job)
+					flusher.Flush()
+				}
+				return
+			}
+			h.writeEvent(w, ev.Type, ev)
+			flusher.Flush()
+		}
+	}
+}
+
+func (h *Handler) writeEvent(w http.ResponseWriter, event string, data interface{}) {
+	payload, _ := json.Marshal(data)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+}
+
 // =============================================================================
 // VALIDATOR
 // =============================================================================
 
 func (h *Handler) CreateValidator(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Moniker     string `json:"moniker"`
-		Commission  string `json:"commission"`
-		StakeAmount string `json:"stakeAmount"`
-		Password    string `json:"password"`
+		node.CreateValidatorRequest
+		Password string `json:"password"`
+		WalletID string `json:"walletId"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.respondError(w, http.StatusBadRequest, "JSON inválido")
 		return
 	}
 
-	if err := h.nodeService.CreateValidator(req.Moniker, req.Commission, req.StakeAmount, req.Password); err != nil {
-		h.respondError(w, http.StatusInternalServerError, err.Error())
+	result, err := h.nodeService.CreateValidator(req.CreateValidatorRequest, req.Password, req.WalletID)
+	if err != nil {
+		h.respondError(w, walletErrorStatus(err, http.StatusBadRequest), err.Error())
 		return
 	}
 
-	h.respondJSON(w, http.StatusOK, map[string]string{"message": "Validador criado com sucesso"})
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Validador criado com sucesso",
+		"tx":      result,
+	})
 }
 
 func (h *Handler) GetValidatorStatus(w http.ResponseWriter, r *http.Request) {
 	status, err := h.nodeService.GetValidatorStatus()
 	if err != nil {
-		h.respondError(w, http.StatusNotFound, err.Error())
+		h.respondError(w, walletErrorStatus(err, http.StatusBadGateway), err.Error())
 		return
 	}
 
@@ -388,33 +745,541 @@ func (h *Handler) GetStakingInfo(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) WithdrawRewards(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Password string `json:"password"`
+		WalletID string `json:"walletId"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.respondError(w, http.StatusBadRequest, "JSON inválido")
 		return
 	}
 
-	if err := h.nodeService.WithdrawRewards(req.Password); err != nil {
-		h.respondError(w, http.StatusBadRequest, err.Error())
+	result, err := h.nodeService.WithdrawRewards(req.Password, req.WalletID)
+	if err != nil {
+		h.respondError(w, walletErrorStatus(err, http.StatusBadRequest), err.Error())
 		return
 	}
 
-	h.respondJSON(w, http.StatusOK, map[string]string{"message": "Recompensas sacadas"})
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Recompensas sacadas",
+		"tx":      result,
+	})
 }
 
 func (h *Handler) Restake(w http.ResponseWriter, r *http.Request) {
 	var req struct {
+		Password   string `json:"password"`
+		WalletID   string `json:"walletId"`
+		FeeReserve string `json:"feeReserve"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "JSON inválido")
+		return
+	}
+
+	result, err := h.nodeService.Restake(req.Password, req.WalletID, req.FeeReserve)
+	if err != nil {
+		h.respondError(w, walletErrorStatus(err, http.StatusBadRequest), err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Restake realizado",
+		"restake": result,
+	})
+}
+
+func (h *Handler) EditValidator(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		node.ValidatorEdit
 		Password string `json:"password"`
+		WalletID string `json:"walletId"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.respondError(w, http.StatusBadRequest, "JSON inválido")
 		return
 	}
 
-	if err := h.nodeService.Restake(req.Password); err != nil {
+	result, err := h.nodeService.EditValidator(req.Password, req.WalletID, req.ValidatorEdit)
+	if err != nil {
+		h.respondError(w, walletErrorStatus(err, http.StatusBadRequest), err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Validador atualizado",
+		"tx":      result,
+	})
+}
+
+func (h *Handler) Unjail(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Password string `json:"password"`
+		WalletID string `json:"walletId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "JSON inválido")
+		return
+	}
+
+	result, err := h.nodeService.Unjail(req.Password, req.WalletID)
+	if err != nil {
+		status := walletErrorStatus(err, http.StatusBadRequest)
+		if errors.Is(err, node.ErrCannotUnjail) {
+			status = http.StatusConflict
+		}
+		h.respondError(w, status, err.Error())
+		return
+	}
+
+	message := "Validador ativo novamente"
+	if !result.Bonded {
+		message = "Unjail enviado; o validador ainda não voltou ao conjunto ativo"
+	}
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message": message,
+		"unjail":  result,
+	})
+}
+
+// =============================================================================
+// DELEGATIONS
+// =============================================================================
+
+func (h *Handler) GetValidators(w http.ResponseWriter, r *http.Request) {
+	validators, err := h.nodeService.GetValidators()
+	if err != nil {
+		h.respondError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"validators": validators,
+	})
+}
+
+func (h *Handler) GetDelegations(w http.ResponseWriter, r *http.Request) {
+	delegations, err := h.nodeService.GetDelegations()
+	if err != nil {
+		h.respondError(w, walletErrorStatus(err, http.StatusBadGateway), err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, delegations)
+}
+
+type delegationRequest struct {
+	Password     string `json:"password"`
+	WalletID     string `json:"walletId"`
+	Validator    string `json:"validator"`
+	DstValidator string `json:"dstValidator"`
+	Amount       string `json:"amount"`
+}
+
+func (h *Handler) Delegate(w http.ResponseWriter, r *http.Request) {
+	var req delegationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "JSON inválido")
+		return
+	}
+
+	result, err := h.nodeService.Delegate(req.Password, req.WalletID, req.Validator, req.Amount)
+	if err != nil {
+		h.respondError(w, walletErrorStatus(err, http.StatusBadRequest), err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message":    "Delegação realizada",
+		"delegation": result,
+	})
+}
+
+func (h *Handler) Undelegate(w http.ResponseWriter, r *http.Request) {
+	var req delegationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "JSON inválido")
+		return
+	}
+
+	result, err := h.nodeService.Undelegate(req.Password, req.WalletID, req.Validator, req.Amount)
+	if err != nil {
+		h.respondError(w, walletErrorStatus(err, http.StatusBadRequest), err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message":    "Delegação removida; os tokens ficam em unbonding até a data de conclusão",
+		"delegation": result,
+	})
+}
+
+func (h *Handler) Redelegate(w http.ResponseWriter, r *http.Request) {
+	var req delegationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "JSON inválido")
+		return
+	}
+
+	result, err := h.nodeService.Redelegate(req.Password, req.WalletID, req.Validator, req.DstValidator, req.Amount)
+	if err != nil {
+		h.respondError(w, walletErrorStatus(err, http.StatusBadRequest), err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message":    "Redelegação realizada",
+		"delegation": result,
+	})
+}
+
+// =============================================================================
+// TRANSACTIONS
+// =============================================================================
+
+// GetTxHistory pages through the tx ledger, newest first. Filters: status,
+// type, wallet, page and limit.
+func (h *Handler) GetTxHistory(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	q := node.TxQuery{
+		Status: params.Get("status"),
+		Type:   params.Get("type"),
+		Wallet: params.Get("wallet"),
+	}
+	q.Page, _ = strconv.Atoi(params.Get("page"))
+	q.Limit, _ = strconv.Atoi(params.Get("limit"))
+
+	h.respondJSON(w, http.StatusOK, h.nodeService.GetTxHistory(q))
+}
+
+func (h *Handler) ImportTxHistory(w http.ResponseWriter, r *http.Request) {
+	imported, err := h.nodeService.ImportTxHistory()
+	if err != nil {
+		h.respondError(w, walletErrorStatus(err, http.StatusBadGateway), err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message":  fmt.Sprintf("%d transações importadas", imported),
+		"imported": imported,
+	})
+}
+
+// =============================================================================
+// GOVERNANCE
+// =============================================================================
+
+// GetProposals lists proposals, newest first; status=active limits them to
+// those in voting period.
+func (h *Handler) GetProposals(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	page, err := h.nodeService.GetProposals(r.URL.Query().Get("status") == "active", limit)
+	if err != nil {
+		h.respondError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, page)
+}
+
+func (h *Handler) GetProposal(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "ID de proposta inválido")
+		return
+	}
+
+	proposal, err := h.nodeService.GetProposal(id)
+	if err != nil {
+		h.respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, proposal)
+}
+
+// Vote takes either option for a plain vote or options with weights for a
+// weighted vote.
+func (h *Handler) Vote(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "ID de proposta inválido")
+		return
+	}
+
+	var req struct {
+		Option   string            `json:"option"`
+		Options  []node.VoteOption `json:"options"`
+		Metadata string            `json:"metadata"`
+		Password string            `json:"password"`
+		WalletID string            `json:"walletId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "JSON inválido")
+		return
+	}
+	if req.Option != "" {
+		req.Options = []node.VoteOption{{Option: req.Option}}
+	}
+
+	result, err := h.nodeService.Vote(req.Password, req.WalletID, id, req.Options, req.Metadata)
+	if err != nil {
+		h.respondError(w, walletErrorStatus(err, http.StatusBadRequest), err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Voto registrado",
+		"tx":      result,
+	})
+}
+
+// =============================================================================
+// UPGRADES
+// =============================================================================
+
+func (h *Handler) GetUpgradeStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := h.nodeService.GetUpgradeStatus()
+	if err != nil {
+		h.respondError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, status)
+}
+
+func (h *Handler) PrepareUpgrade(w http.ResponseWriter, r *http.Request) {
+	job, err := h.nodeService.StartJob(node.JobPrepareUpgrade)
+	if err != nil {
+		h.respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusAccepted, job)
+}
+
+// =============================================================================
+// BINARY VERSIONS
+// =============================================================================
+
+func (h *Handler) GetVersions(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, http.StatusOK, h.nodeService.GetVersions())
+}
+
+// InstallVersion installs the given version, or the latest with an empty
+// one, as a job.
+func (h *Handler) InstallVersion(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "JSON inválido")
+		return
+	}
+
+	job, err := h.nodeService.StartInstallVersion(req.Version)
+	if err != nil {
+		h.respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusAccepted, job)
+}
+
+func (h *Handler) ActivateVersion(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "JSON inválido")
+		return
+	}
+
+	if err := h.nodeService.ActivateVersion(req.Version); err != nil {
+		status := http.StatusConflict
+		if errors.Is(err, node.ErrVersionNotInstalled) {
+			status = http.StatusNotFound
+		}
+		h.respondError(w, status, err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]string{
+		"message": "Versão ativada",
+		"version": req.Version,
+	})
+}
+
+func (h *Handler) RollbackVersion(w http.ResponseWriter, r *http.Request) {
+	version, err := h.nodeService.RollbackVersion()
+	if err != nil {
+		h.respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]string{
+		"message": "Versão anterior restaurada",
+		"version": version,
+	})
+}
+
+func (h *Handler) SetReleaseIndex(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "JSON inválido")
+		return
+	}
+
+	if err := h.nodeService.SetReleaseIndex(req.URL); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]string{"message": "Índice de versões atualizado"})
+}
+
+// =============================================================================
+// RUNTIME
+// =============================================================================
+
+func (h *Handler) GetRuntimeConfig(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, http.StatusOK, h.nodeService.GetRuntimeConfig())
+}
+
+// SaveRuntimeConfig replaces node-runtime.json. Fields left out keep their
+// current values.
+func (h *Handler) SaveRuntimeConfig(w http.ResponseWriter, r *http.Request) {
+	cfg := h.nodeService.GetRuntimeConfig()
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		h.respondError(w, http.StatusBadRequest, "JSON inválido")
+		return
+	}
+
+	if err := h.nodeService.SaveRuntimeConfig(cfg); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]string{"message": "Configuração de execução salva"})
+}
+
+// =============================================================================
+// DOWNLOAD VERIFICATION
+// =============================================================================
+
+func (h *Handler) GetVerification(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"config":     h.nodeService.GetVerifyConfig(),
+		"quarantine": h.nodeService.GetQuarantine(),
+	})
+}
+
+func (h *Handler) SaveVerification(w http.ResponseWriter, r *http.Request) {
+	var cfg node.VerifyConfig
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		h.respondError(w, http.StatusBadRequest, "JSON inválido")
+		return
+	}
+
+	if err := h.nodeService.SaveVerifyConfig(cfg); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]string{"message": "Verificação configurada"})
+}
+
+// =============================================================================
+// AUTO-COMPOUND
+// =============================================================================
+
+func (h *Handler) GetAutoCompound(w http.ResponseWriter, r *http.Request) {
+	resp := map[string]interface{}{
+		"config": h.nodeService.GetAutoCompoundConfig(),
+		"hotKey": h.nodeService.GetHotKey(),
+	}
+	if next := h.nodeService.NextCompoundAt(); !next.IsZero() {
+		resp["nextRunAt"] = next.Unix()
+	}
+	h.respondJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) SaveAutoCompoundConfig(w http.ResponseWriter, r *http.Request) {
+	var cfg node.AutoCompoundConfig
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		h.respondError(w, http.StatusBadRequest, "JSON inválido")
+		return
+	}
+
+	if err := h.nodeService.SaveAutoCompoundConfig(cfg); err != nil {
 		h.respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	h.respondJSON(w, http.StatusOK, map[string]string{"message": "Restake realizado"})
+	h.respondJSON(w, http.StatusOK, map[string]string{"message": "Configuração salva"})
+}
+
+func (h *Handler) AuthorizeAutoCompound(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Password  string `json:"password"`
+		WalletID  string `json:"walletId"`
+		FeeBudget string `json:"feeBudget"`
+		GrantDays int    `json:"grantDays"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "JSON inválido")
+		return
+	}
+	if req.FeeBudget == "" {
+		req.FeeBudget = "0"
+	}
+
+	result, err := h.nodeService.AuthorizeAutoCompound(req.Password, req.WalletID, req.FeeBudget, req.GrantDays)
+	if err != nil {
+		h.respondError(w, walletErrorStatus(err, http.StatusBadRequest), err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Auto-compound autorizado",
+		"hotKey":  h.nodeService.GetHotKey(),
+		"tx":      result,
+	})
+}
+
+func (h *Handler) RevokeAutoCompound(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Password string `json:"password"`
+		WalletID string `json:"walletId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "JSON inválido")
+		return
+	}
+
+	result, err := h.nodeService.RevokeAutoCompound(req.Password, req.WalletID)
+	if err != nil {
+		h.respondError(w, walletErrorStatus(err, http.StatusBadRequest), err.Error())
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Auto-compound revogado",
+		"tx":      result,
+	})
+}
+
+func (h *Handler) RunAutoCompound(w http.ResponseWriter, r *http.Request) {
+	record := h.nodeService.RunAutoCompound("manual")
+	if record.Error != "" {
+		h.respondError(w, http.StatusBadRequest, record.Error)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, record)
+}
+
+func (h *Handler) GetAutoCompoundHistory(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"runs": h.nodeService.GetCompoundHistory(),
+	})
 }
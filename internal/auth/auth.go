@@ -1,7 +1,6 @@
 package auth
 
 import (
-	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
@@ -108,20 +107,46 @@ func (s *Service) ValidateToken(tokenString string) error {
 }
 
 func (s *Service) Middleware(next http.Handler) http.Handler {
+	return s.authenticate(next, false)
+}
+
+// StreamMiddleware is Middleware for the SSE routes, which also accepts the
+// token in the query as EventSource cannot send headers.
+func (s *Service) StreamMiddleware(next http.Handler) http.Handler {
+	return s.authenticate(next, true)
+}
+
+func (s *Service) authenticate(next http.Handler, allowQuery bool) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
+		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+		if authHeader == "" && allowQuery {
+			tokenString = r.URL.Query().Get("token")
+		}
+		if tokenString == "" {
 			http.Error(w, `{"error": "Token não fornecido"}`, http.StatusUnauthorized)
 			return
 		}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		if err := s.ValidateToken(tokenString); err != nil {
 			http.Error(w, `{"error": "Token inválido"}`, http.StatusUnauthorized)
 			return
 		}
 
-		next.ServeHTTP(w, r.WithContext(context.Background()))
+		// Keep the request context so streaming handlers notice disconnects
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RedactToken hides a token passed in the query from the request log. It
+// has to run before middleware.Logger, which logs r.RequestURI.
+func RedactToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query(); q.Has("token") {
+			q.Set("token", "REDACTED")
+			r.RequestURI = r.URL.Path + "?" + q.Encode()
+		}
+		next.ServeHTTP(w, r)
 	})
 }
 
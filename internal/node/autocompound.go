@@ -0,0 +1,515 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	sdkmath "cosmossdk.io/math"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/authz"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/cosmos/go-bip39"
+	"github.com/tickfy/tickfy-validator-setup/internal/tx"
+)
+
+// =============================================================================
+// AUTO-COMPOUND
+// =============================================================================
+
+const maxCompoundRecords = 200
+
+// The messages the hot key is allowed to run on behalf of the wallet
+var compoundGrantMsgs = []string{
+	"/cosmos.distribution.v1beta1.MsgWithdrawDelegatorReward",
+	"/cosmos.distribution.v1beta1.MsgWithdrawValidatorCommission",
+}
+
+// AutoCompoundConfig is read from autocompound.json in the data dir.
+// Amounts are in base units of the bond denom.
+type AutoCompoundConfig struct {
+	Enabled bool `json:"enabled"`
+	// Interval is a duration such as "6h"; DailyAt ("15:04", local time)
+	// takes precedence when set.
+	Interval string `json:"interval"`
+	DailyAt  string `json:"dailyAt,omitempty"`
+	// MinReward skips runs whose rewards plus commission are below it.
+	MinReward string `json:"minReward"`
+	// KeepLiquid is the wallet balance left undelegated after a run.
+	KeepLiquid string `json:"keepLiquid"`
+}
+
+// CompoundHotKey is the key that signs the scheduled runs. It only holds an
+// authz grant from the wallet for withdrawing rewards and delegating to our
+// validator, plus a small balance for fees, so it is stored unencrypted.
+type CompoundHotKey struct {
+	Mnemonic  string `json:"mnemonic"`
+	Address   string `json:"address"`
+	Granter   string `json:"granter"`
+	Validator string `json:"validator"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+type CompoundRecord struct {
+	Time       int64  `json:"time"`
+	Trigger    string `json:"trigger"` // "schedule" or "manual"
+	Denom      string `json:"denom,omitempty"`
+	Rewards    string `json:"rewards,omitempty"`
+	Commission string `json:"commission,omitempty"`
+	Delegated  string `json:"delegated,omitempty"`
+	TxHash     string `json:"txHash,omitempty"`
+	Skipped    string `json:"skipped,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func defaultAutoCompoundConfig() AutoCompoundConfig {
+	return AutoCompoundConfig{
+		Interval:   "24h",
+		MinReward:  "1000000",
+		KeepLiquid: "1000000",
+	}
+}
+
+func (s *Service) GetAutoCompoundConfig() AutoCompoundConfig {
+	cfg := defaultAutoCompoundConfig()
+	if data, err := os.ReadFile(filepath.Join(s.dataDir, "autocompound.json")); err == nil {
+		json.Unmarshal(data, &cfg)
+	}
+	return cfg
+}
+
+func (s *Service) SaveAutoCompoundConfig(cfg AutoCompoundConfig) error {
+	if cfg.DailyAt != "" {
+		if _, err := time.Parse("15:04", cfg.DailyAt); err != nil {
+			return errors.New("horário inválido, use HH:MM")
+		}
+	} else if d, err := time.ParseDuration(cfg.Interval); err != nil || d < time.Hour {
+		return errors.New("intervalo inválido, use por exemplo \"6h\" (mínimo 1h)")
+	}
+	if _, ok := sdkmath.NewIntFromString(cfg.MinReward); !ok {
+		return errors.New("recompensa mínima inválida")
+	}
+	if _, ok := sdkmath.NewIntFromString(cfg.KeepLiquid); !ok {
+		return errors.New("reserva líquida inválida")
+	}
+	if cfg.Enabled && s.loadHotKey() == nil {
+		return errors.New("autorize a chave de auto-compound antes de ativar")
+	}
+	enabling := cfg.Enabled && !s.GetAutoCompoundConfig().Enabled
+
+	data, _ := json.MarshalIndent(cfg, "", "  ")
+	if err := os.WriteFile(filepath.Join(s.dataDir, "autocompound.json"), data, 0600); err != nil {
+		return err
+	}
+
+	// A first schedule counts from now rather than from startup
+	if enabling && len(s.GetCompoundHistory()) == 0 {
+		s.compoundMutex.Lock()
+		s.lastCompoundAt = time.Now()
+		s.compoundMutex.Unlock()
+	}
+	return nil
+}
+
+func (s *Service) loadHotKey() *CompoundHotKey {
+	data, err := os.ReadFile(filepath.Join(s.dataDir, "autocompound-key.json"))
+	if err != nil {
+		return nil
+	}
+	var key CompoundHotKey
+	if json.Unmarshal(data, &key) != nil || key.Mnemonic == "" {
+		return nil
+	}
+	return &key
+}
+
+// GetHotKey returns the hot key without its mnemonic, or nil.
+func (s *Service) GetHotKey() *CompoundHotKey {
+	key := s.loadHotKey()
+	if key != nil {
+		key.Mnemonic = ""
+	}
+	return key
+}
+
+// AuthorizeAutoCompound creates a hot key, or reuses the current one, and
+// grants it from the wallet, for grantDays, the permissions a run needs.
+// feeBudget, in base units of the bond denom, is sent to it for fees. A key
+// granted by another wallet has to be revoked with that wallet first, as
+// only it can revoke the grants.
+func (s *Service) AuthorizeAutoCompound(password, walletID, feeBudget string, grantDays int) (*tx.Result, error) {
+	key, _, err := s.unlockKey(walletID, password)
+	if err != nil {
+		return nil, err
+	}
+	if grantDays <= 0 {
+		grantDays = 365
+	}
+	budget, ok := sdkmath.NewIntFromString(feeBudget)
+	if !ok || budget.IsNegative() {
+		return nil, errors.New("orçamento para taxas inválido")
+	}
+
+	denom, err := s.getBondDenom()
+	if err != nil {
+		return nil, err
+	}
+
+	hot := s.loadHotKey()
+	if hot != nil && hot.Granter != key.Address() {
+		return nil, fmt.Errorf("auto-compound já está autorizado por %s, revogue com essa carteira primeiro", hot.Granter)
+	}
+	if hot == nil {
+		entropy, _ := bip39.NewEntropy(256)
+		mnemonic, _ := bip39.NewMnemonic(entropy)
+		hot = &CompoundHotKey{Mnemonic: mnemonic}
+	}
+	hotKey, err := tx.KeyFromMnemonic(hot.Mnemonic)
+	if err != nil {
+		return nil, err
+	}
+
+	granter := key.Address()
+	grantee := hotKey.Address()
+	validator := key.ValidatorAddress()
+	expiration := time.Now().AddDate(0, 0, grantDays)
+
+	authorizations := []authz.Authorization{
+		authz.NewGenericAuthorization(compoundGrantMsgs[0]),
+		authz.NewGenericAuthorization(compoundGrantMsgs[1]),
+		&stakingtypes.StakeAuthorization{
+			Validators: &stakingtypes.StakeAuthorization_AllowList{
+				AllowList: &stakingtypes.StakeAuthorization_Validators{Address: []string{validator}},
+			},
+			AuthorizationType: stakingtypes.AuthorizationType_AUTHORIZATION_TYPE_DELEGATE,
+		},
+	}
+
+	var msgs []sdk.Msg
+	for _, a := range authorizations {
+		grant, err := authz.NewGrant(time.Now(), a, &expiration)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, &authz.MsgGrant{Granter: granter, Grantee: grantee, Grant: grant})
+	}
+	if budget.IsPositive() {
+		msgs = append(msgs, &banktypes.MsgSend{
+			FromAddress: granter,
+			ToAddress:   grantee,
+			Amount:      sdk.NewCoins(sdk.NewCoin(denom, budget)),
+		})
+	}
+
+	result, err := s.sendTx(key, msgs, "auto-compound grant")
+	if err != nil {
+		return nil, fmt.Errorf("erro ao autorizar auto-compound: %v", err)
+	}
+
+	hot.Address = grantee
+	hot.Granter = granter
+	hot.Validator = validator
+	hot.ExpiresAt = expiration.Unix()
+	data, _ := json.MarshalIndent(hot, "", "  ")
+	if err := os.WriteFile(filepath.Join(s.dataDir, "autocompound-key.json"), data, 0600); err != nil {
+		return nil, err
+	}
+
+	s.addLog(fmt.Sprintf("Auto-compound authorized for %s until %s", grantee, expiration.Format("2006-01-02")))
+	return result, nil
+}
+
+// RevokeAutoCompound returns what is left of the fee budget to the wallet,
+// revokes the grants of the hot key, disables the schedule and deletes the
+// key.
+func (s *Service) RevokeAutoCompound(password, walletID string) (*tx.Result, error) {
+	hot := s.loadHotKey()
+	if hot == nil {
+		return nil, errors.New("auto-compound não está autorizado")
+	}
+
+	key, _, err := s.unlockKey(walletID, password)
+	if err != nil {
+		return nil, err
+	}
+	if key.Address() != hot.Granter {
+		return nil, fmt.Errorf("a autorização foi concedida por %s, use essa carteira", hot.Granter)
+	}
+
+	// The grants only allow compounding into our validator, while the
+	// budget would be lost with the key, so it is returned first
+	if _, err := s.sweepHotKey(hot); err != nil {
+		return nil, fmt.Errorf("erro ao devolver o orçamento para taxas: %v", err)
+	}
+
+	var msgs []sdk.Msg
+	for _, msgType := range append(compoundGrantMsgs, sdk.MsgTypeURL(&stakingtypes.MsgDelegate{})) {
+		msgs = append(msgs, &authz.MsgRevoke{Granter: hot.Granter, Grantee: hot.Address, MsgTypeUrl: msgType})
+	}
+
+	result, err := s.sendTx(key, msgs, "auto-compound revoke")
+	if err != nil {
+		return nil, fmt.Errorf("erro ao revogar auto-compound: %v", err)
+	}
+
+	cfg := s.GetAutoCompoundConfig()
+	cfg.Enabled = false
+	data, _ := json.MarshalIndent(cfg, "", "  ")
+	os.WriteFile(filepath.Join(s.dataDir, "autocompound.json"), data, 0600)
+	os.Remove(filepath.Join(s.dataDir, "autocompound-key.json"))
+
+	s.addLog("Auto-compound authorization revoked")
+	return result, nil
+}
+
+// sweepHotKey sends the balance of the hot key, minus the fee, back to the
+// granter. A balance that does not cover the fee is left behind.
+func (s *Service) sweepHotKey(hot *CompoundHotKey) (*tx.Result, error) {
+	hotKey, err := tx.KeyFromMnemonic(hot.Mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	denom, err := s.getBondDenom()
+	if err != nil {
+		return nil, err
+	}
+
+	var balanceResp struct {
+		Balance decCoin `json:"balance"`
+	}
+	if err := s.queryChain(fmt.Sprintf("/cosmos/bank/v1beta1/balances/%s/by_denom?denom=%s", hot.Address, denom), &balanceResp); err != nil {
+		return nil, fmt.Errorf("erro ao consultar saldo: %v", err)
+	}
+	balance := amountOf([]decCoin{balanceResp.Balance}, denom)
+	if !balance.IsPositive() {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	msg := &banktypes.MsgSend{FromAddress: hot.Address, ToAddress: hot.Granter, Amount: sdk.NewCoins(sdk.NewCoin(denom, balance))}
+	gas, fees, err := s.txClient().Estimate(ctx, hotKey, []sdk.Msg{msg}, tx.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao estimar taxa: %v", err)
+	}
+	amount := balance.Sub(fees.AmountOf(denom))
+	if !amount.IsPositive() {
+		return nil, nil
+	}
+
+	msg.Amount = sdk.NewCoins(sdk.NewCoin(denom, amount))
+	result, err := s.sendTxWith(hotKey, []sdk.Msg{msg}, tx.Options{Memo: "auto-compound budget return", GasLimit: gas, Fees: fees})
+	if err != nil {
+		return result, err
+	}
+	s.addLog(fmt.Sprintf("Returned %s%s of auto-compound fee budget to %s (tx %s)", amount, denom, hot.Granter, result.TxHash))
+	return result, nil
+}
+
+// RunAutoCompound compounds once with the hot key and records the run. A
+// run started while another is broadcasting is skipped and not recorded.
+func (s *Service) RunAutoCompound(trigger string) CompoundRecord {
+	s.compoundMutex.Lock()
+	if s.compoundRunning {
+		s.compoundMutex.Unlock()
+		return CompoundRecord{Time: time.Now().Unix(), Trigger: trigger, Skipped: "já existe uma execução em andamento"}
+	}
+	s.compoundRunning = true
+	s.compoundMutex.Unlock()
+
+	record := s.compound()
+	record.Time = time.Now().Unix()
+	record.Trigger = trigger
+
+	s.compoundMutex.Lock()
+	s.lastCompoundAt = time.Now()
+	s.saveCompoundRecord(record)
+	s.compoundRunning = false
+	s.compoundMutex.Unlock()
+
+	switch {
+	case record.Error != "":
+		s.addLog(fmt.Sprintf("Auto-compound failed: %s", record.Error))
+	case record.Skipped != "":
+		s.addLog(fmt.Sprintf("Auto-compound skipped: %s", record.Skipped))
+	default:
+		s.addLog(fmt.Sprintf("Auto-compound delegated %s%s (tx %s)", record.Delegated, record.Denom, record.TxHash))
+	}
+	return record
+}
+
+func (s *Service) compound() CompoundRecord {
+	var record CompoundRecord
+
+	hot := s.loadHotKey()
+	if hot == nil {
+		record.Error = "auto-compound não está autorizado"
+		return record
+	}
+	if time.Now().Unix() > hot.ExpiresAt {
+		record.Error = "autorização expirada, autorize novamente"
+		return record
+	}
+	hotKey, err := tx.KeyFromMnemonic(hot.Mnemonic)
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+
+	cfg := s.GetAutoCompoundConfig()
+	minReward, _ := sdkmath.NewIntFromString(cfg.MinReward)
+	keepLiquid, _ := sdkmath.NewIntFromString(cfg.KeepLiquid)
+
+	denom, err := s.getBondDenom()
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+	record.Denom = denom
+
+	rewards, commission, err := s.queryPendingRewards(hot.Granter, hot.Validator, denom)
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+	record.Rewards = rewards.String()
+	record.Commission = commission.String()
+
+	withdrawn := rewards.Add(commission)
+	if !minReward.IsNil() && withdrawn.LT(minReward) {
+		record.Skipped = fmt.Sprintf("recompensas abaixo do mínimo (%s < %s)", withdrawn, minReward)
+		return record
+	}
+
+	var balanceResp struct {
+		Balance decCoin `json:"balance"`
+	}
+	if err := s.queryChain(fmt.Sprintf("/cosmos/bank/v1beta1/balances/%s/by_denom?denom=%s", hot.Granter, denom), &balanceResp); err != nil {
+		record.Error = fmt.Sprintf("erro ao consultar saldo: %v", err)
+		return record
+	}
+	balance := amountOf([]decCoin{balanceResp.Balance}, denom)
+
+	// Delegate what was withdrawn, but never dip below the liquid reserve
+	amount := withdrawn
+	if !keepLiquid.IsNil() {
+		if available := balance.Add(withdrawn).Sub(keepLiquid); available.LT(amount) {
+			amount = available
+		}
+	}
+	if !amount.IsPositive() {
+		record.Skipped = "saldo não cobre a reserva líquida"
+		return record
+	}
+
+	exec, err := newMsgExec(hotKey.Address(), restakeMsgs(hot.Granter, hot.Validator, denom, rewards, commission, amount))
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+
+	result, err := s.sendTx(hotKey, []sdk.Msg{exec}, "auto-compound")
+	if result != nil {
+		record.TxHash = result.TxHash
+	}
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+	record.Delegated = amount.String()
+	return record
+}
+
+func newMsgExec(grantee string, msgs []sdk.Msg) (*authz.MsgExec, error) {
+	anys := make([]*codectypes.Any, len(msgs))
+	for i, msg := range msgs {
+		any, err := codectypes.NewAnyWithValue(msg)
+		if err != nil {
+			return nil, err
+		}
+		anys[i] = any
+	}
+	return &authz.MsgExec{Grantee: grantee, Msgs: anys}, nil
+}
+
+// nextCompoundAt returns when the schedule should run next.
+func (s *Service) nextCompoundAt(cfg AutoCompoundConfig, last time.Time) time.Time {
+	if cfg.DailyAt != "" {
+		at, _ := time.Parse("15:04", cfg.DailyAt)
+		now := time.Now()
+		next := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, time.Local)
+		// Today's run, if it has not happened; a past time runs right away
+		if !next.After(last) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next
+	}
+
+	interval, err := time.ParseDuration(cfg.Interval)
+	if err != nil || interval < time.Hour {
+		interval = 24 * time.Hour
+	}
+	return last.Add(interval)
+}
+
+// runAutoCompoundScheduler checks the schedule every minute. Without a
+// previous run the schedule counts from startup, so a daily time already
+// past does not run as soon as the server starts.
+func (s *Service) runAutoCompoundScheduler() {
+	s.compoundMutex.Lock()
+	s.lastCompoundAt = time.Now()
+	if history := s.GetCompoundHistory(); len(history) > 0 {
+		s.lastCompoundAt = time.Unix(history[0].Time, 0)
+	}
+	s.compoundMutex.Unlock()
+
+	for range time.Tick(time.Minute) {
+		cfg := s.GetAutoCompoundConfig()
+		if !cfg.Enabled {
+			continue
+		}
+		s.compoundMutex.Lock()
+		due := !time.Now().Before(s.nextCompoundAt(cfg, s.lastCompoundAt))
+		s.compoundMutex.Unlock()
+		if due {
+			s.RunAutoCompound("schedule")
+		}
+	}
+}
+
+// NextCompoundAt returns the next scheduled run, or zero when disabled.
+func (s *Service) NextCompoundAt() time.Time {
+	cfg := s.GetAutoCompoundConfig()
+	if !cfg.Enabled {
+		return time.Time{}
+	}
+	s.compoundMutex.Lock()
+	defer s.compoundMutex.Unlock()
+	return s.nextCompoundAt(cfg, s.lastCompoundAt)
+}
+
+// GetCompoundHistory returns recorded runs, most recent first.
+func (s *Service) GetCompoundHistory() []CompoundRecord {
+	records := []CompoundRecord{}
+	if data, err := os.ReadFile(filepath.Join(s.dataDir, "autocompound-history.json")); err == nil {
+		json.Unmarshal(data, &records)
+	}
+	return records
+}
+
+// saveCompoundRecord must be called with s.compoundMutex held.
+func (s *Service) saveCompoundRecord(record CompoundRecord) {
+	records := append([]CompoundRecord{record}, s.GetCompoundHistory()...)
+	if len(records) > maxCompoundRecords {
+		records = records[:maxCompoundRecords]
+	}
+
+	data, _ := json.MarshalIndent(records, "", "  ")
+	os.WriteFile(filepath.Join(s.dataDir, "autocompound-history.json"), data, 0600)
+}
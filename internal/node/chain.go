@@ -0,0 +1,163 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/tickfy/tickfy-validator-setup/internal/tx"
+)
+
+// =============================================================================
+// CHAIN
+// =============================================================================
+
+const (
+	restEndpoint   = "http://localhost:1317"
+	defaultChainID = "tickfyblockchain"
+	txWaitTimeout  = 30 * time.Second
+)
+
+func (s *Service) getChainID() string {
+	var cfg NodeConfig
+	if data, err := os.ReadFile(filepath.Join(s.dataDir, "node-config.json")); err == nil {
+		json.Unmarshal(data, &cfg)
+	}
+	if cfg.ChainID == "" {
+		return defaultChainID
+	}
+	return cfg.ChainID
+}
+
+var minGasPricesRe = regexp.MustCompile(`(?m)^\s*minimum-gas-prices\s*=\s*"([^"]*)"`)
+
+// getMinGasPrices reads minimum-gas-prices from the node's app.toml, so our
+// own transactions pay at least what our node accepts.
+func (s *Service) getMinGasPrices() sdk.DecCoins {
+	data, err := os.ReadFile(filepath.Join(s.getNodeHome(), "config", "app.toml"))
+	if err != nil {
+		return nil
+	}
+	m := minGasPricesRe.FindSubmatch(data)
+	if m == nil {
+		return nil
+	}
+	prices, err := sdk.ParseDecCoins(string(m[1]))
+	if err != nil {
+		return nil
+	}
+	return prices
+}
+
+func (s *Service) txClient() *tx.Client {
+	client := tx.NewClient(restEndpoint, s.getChainID())
+	client.GasPrices = s.getMinGasPrices()
+	return client
+}
+
+// queryChain GETs a REST path of the local node into out.
+func (s *Service) queryChain(path string, out interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return s.txClient().Query(ctx, path, out)
+}
+
+// getBondDenom returns the staking denom from the chain params.
+func (s *Service) getBondDenom() (string, error) {
+	var resp struct {
+		Params struct {
+			BondDenom string `json:"bond_denom"`
+		} `json:"params"`
+	}
+	if err := s.queryChain("/cosmos/staking/v1beta1/params", &resp); err != nil {
+		return "", fmt.Errorf("erro ao consultar parâmetros de staking: %v", err)
+	}
+	return resp.Params.BondDenom, nil
+}
+
+// getUnbondingTime returns how long undelegated tokens stay locked, from
+// the chain params.
+func (s *Service) getUnbondingTime() (time.Duration, error) {
+	var resp struct {
+		Params struct {
+			UnbondingTime string `json:"unbonding_time"`
+		} `json:"params"`
+	}
+	if err := s.queryChain("/cosmos/staking/v1beta1/params", &resp); err != nil {
+		return 0, fmt.Errorf("erro ao consultar parâmetros de staking: %v", err)
+	}
+	return time.ParseDuration(resp.Params.UnbondingTime)
+}
+
+// getOperatorAddress returns the valoper address of the active wallet,
+// which is our validator once CreateValidator has run.
+func (s *Service) getOperatorAddress() (string, string, error) {
+	address, _, err := s.GetWalletInfo()
+	if err != nil {
+		return "", "", err
+	}
+	valoper, err := tx.ValidatorAddressOf(address)
+	if err != nil {
+		return "", "", err
+	}
+	return address, valoper, nil
+}
+
+// unlockKey decrypts the wallet used for signing and derives its key.
+func (s *Service) unlockKey(walletID, password string) (*tx.Key, *WalletData, error) {
+	mnemonic, wallet, err := s.getMnemonic(walletID, password)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := tx.KeyFromMnemonic(mnemonic)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, wallet, nil
+}
+
+// sendTx signs and broadcasts msgs and waits for them to be included in a
+// block. If that takes too long the CheckTx result is returned as is. Every
+// broadcast tx is recorded in the ledger, where the poller picks up those
+// still pending.
+func (s *Service) sendTx(key *tx.Key, msgs []sdk.Msg, memo string) (*tx.Result, error) {
+	return s.sendTxWith(key, msgs, tx.Options{Memo: memo})
+}
+
+// sendTxWith is sendTx with explicit gas and fee options.
+func (s *Service) sendTxWith(key *tx.Key, msgs []sdk.Msg, opts tx.Options) (*tx.Result, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), txWaitTimeout)
+	defer cancel()
+
+	client := s.txClient()
+	result, err := client.Send(ctx, key, msgs, opts)
+	if result != nil {
+		s.recordTx(key.Address(), msgs, opts.Memo, result)
+	}
+	if err != nil {
+		return result, err
+	}
+	s.addLog(fmt.Sprintf("Transaction %s broadcast (%s)", result.TxHash, sdk.MsgTypeURL(msgs[0])))
+
+	included, err := client.WaitForTx(ctx, result.TxHash)
+	if err != nil {
+		var txErr *tx.TxError
+		if errors.As(err, &txErr) {
+			included.Fee = result.Fee
+			s.updateTxRecord(included)
+			return included, err
+		}
+		s.addLog(fmt.Sprintf("Transaction %s not confirmed yet: %v", result.TxHash, err))
+		return result, nil
+	}
+	included.Fee = result.Fee
+	s.updateTxRecord(included)
+	return included, nil
+}
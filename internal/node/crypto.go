@@ -0,0 +1,222 @@
+package node
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// =============================================================================
+// WALLET ENCRYPTION
+// =============================================================================
+
+const (
+	// walletVersionLegacy keys AES-GCM with sha256(password + salt). Only
+	// decrypted, to migrate the wallet on the next use of its password.
+	walletVersionLegacy = 1
+	// walletVersionArgon2 keys AES-GCM with Argon2id, using the parameters
+	// stored next to the ciphertext.
+	walletVersionArgon2 = 2
+)
+
+var ErrWrongPassword = errors.New("senha incorreta")
+
+// KDFParams are the Argon2id parameters a wallet was encrypted with, so
+// they can be raised later without breaking existing wallets.
+type KDFParams struct {
+	Name    string `json:"name"`
+	Memory  uint32 `json:"memory"` // KiB
+	Time    uint32 `json:"time"`
+	Threads uint8  `json:"threads"`
+}
+
+func defaultKDFParams() *KDFParams {
+	return &KDFParams{
+		Name:    "argon2id",
+		Memory:  64 * 1024,
+		Time:    3,
+		Threads: 4,
+	}
+}
+
+func generateSalt() string {
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	return hex.EncodeToString(salt)
+}
+
+func deriveKey(password, salt string, version int, kdf *KDFParams) ([]byte, error) {
+	switch version {
+	case 0, walletVersionLegacy:
+		key := sha256.Sum256([]byte(password + salt))
+		return key[:], nil
+	case walletVersionArgon2:
+		if kdf == nil || kdf.Name != "argon2id" {
+			return nil, errors.New("parâmetros de KDF inválidos")
+		}
+		saltBytes, err := hex.DecodeString(salt)
+		if err != nil {
+			return nil, err
+		}
+		return argon2.IDKey([]byte(password), saltBytes, kdf.Time, kdf.Memory, kdf.Threads, 32), nil
+	default:
+		return nil, fmt.Errorf("versão de criptografia desconhecida: %d", version)
+	}
+}
+
+// sealMnemonic encrypts the mnemonic into w with a fresh salt and the
+// current KDF parameters.
+func sealMnemonic(w *WalletData, mnemonic, password string) error {
+	salt := generateSalt()
+	kdf := defaultKDFParams()
+	key, err := deriveKey(password, salt, walletVersionArgon2, kdf)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := encryptData(mnemonic, key)
+	if err != nil {
+		return err
+	}
+
+	w.EncryptedMnemonic = encrypted
+	w.Salt = salt
+	w.Version = walletVersionArgon2
+	w.KDF = kdf
+	return nil
+}
+
+func openMnemonic(w *WalletData, password string) (string, error) {
+	key, err := deriveKey(password, w.Salt, w.Version, w.KDF)
+	if err != nil {
+		return "", err
+	}
+	return decryptData(w.EncryptedMnemonic, key)
+}
+
+// needsReencrypt reports whether w uses the legacy key derivation or
+// weaker parameters than the current ones.
+func needsReencrypt(w *WalletData) bool {
+	if w.Version != walletVersionArgon2 || w.KDF == nil {
+		return true
+	}
+	return *w.KDF != *defaultKDFParams()
+}
+
+// upgradeWallet re-encrypts w with the current parameters if it needs it
+// and password opens it. It returns whether w was changed.
+func upgradeWallet(w *WalletData, password string) (bool, error) {
+	if !needsReencrypt(w) {
+		return false, nil
+	}
+	mnemonic, err := openMnemonic(w, password)
+	if err != nil {
+		return false, err
+	}
+	if err := sealMnemonic(w, mnemonic, password); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// upgradeLegacyWallets re-encrypts, in store, the wallets that password
+// opens. Wallets with another password are left for their own migration.
+func (s *Service) upgradeLegacyWallets(store *WalletsStore, password string) int {
+	upgraded := 0
+	for i := range store.Wallets {
+		if ok, _ := upgradeWallet(&store.Wallets[i], password); ok {
+			upgraded++
+		}
+	}
+	if upgraded > 0 {
+		s.addLog(fmt.Sprintf("Re-encrypted %d wallet(s) with Argon2id", upgraded))
+	}
+	return upgraded
+}
+
+// ReencryptWallets upgrades every wallet that password opens to the current
+// encryption and returns how many were upgraded, how many were already up
+// to date and the IDs of those the password did not open.
+func (s *Service) ReencryptWallets(password string) (int, int, []string, error) {
+	store, _ := s.loadWalletsStore()
+	if len(store.Wallets) == 0 {
+		return 0, 0, nil, ErrNoWallet
+	}
+
+	upgraded, current := 0, 0
+	failed := []string{}
+	for i := range store.Wallets {
+		w := &store.Wallets[i]
+		ok, err := upgradeWallet(w, password)
+		switch {
+		case err != nil:
+			failed = append(failed, w.ID)
+		case ok:
+			upgraded++
+		default:
+			current++
+		}
+	}
+
+	if upgraded > 0 {
+		if err := s.saveWalletsStore(store); err != nil {
+			return 0, 0, nil, err
+		}
+		s.addLog(fmt.Sprintf("Re-encrypted %d wallet(s) with Argon2id", upgraded))
+	}
+	return upgraded, current, failed, nil
+}
+
+func encryptData(plaintext string, key []byte) (string, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	rand.Read(nonce)
+
+	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return hex.EncodeToString(ciphertext), nil
+}
+
+func decryptData(cipherHex string, key []byte) (string, error) {
+	ciphertext, err := hex.DecodeString(cipherHex)
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return "", errors.New("ciphertext muito curto")
+	}
+
+	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrWrongPassword
+	}
+
+	return string(plaintext), nil
+}
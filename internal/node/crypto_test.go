@@ -0,0 +1,180 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	testPassword = "correct horse"
+	testSalt     = "00112233445566778899aabbccddeeff"
+
+	// testMnemonic encrypted with testPassword and testSalt, keyed with
+	// sha256(password + salt) as wallets before Argon2id were
+	legacyCiphertext = "4d3e4a058e08a3edaadeeefa5883b6860ec4c4b69ff91dff302e6f8ccc38072d3caaa252b1ed0c5327c6aee0e44371909dc60160958bb84498e490e182dcff847eccf91df678f65c31efbdeafea084ba7e905760714e6180d5297a7ef72a3d23e245a4d2e8950f263a44364b271f6c56f46f0014ad99e24696"
+	// and keyed with Argon2id using defaultKDFParams
+	argon2Ciphertext = "cee59d24832c3cacf6b8c0fb6ac109c4f24f545be5ce2eb99c79e546e84415fd6ddc06b5715591c726a639af5d02bf02123b5899fb08897a0a083b6502deb8f12448c6568b5b5b283c407305974728628d5402d0f98324adb6dbc53e94d68f45e06fbe462d6e93f1573fa3f7097d360d2455457d49d9105fc9"
+)
+
+func legacyWallet(id string, version int) WalletData {
+	return WalletData{ID: id, EncryptedMnemonic: legacyCiphertext, Salt: testSalt, Version: version}
+}
+
+func argon2Wallet(id string) WalletData {
+	return WalletData{ID: id, EncryptedMnemonic: argon2Ciphertext, Salt: testSalt, Version: walletVersionArgon2, KDF: defaultKDFParams()}
+}
+
+func TestOpenMnemonic(t *testing.T) {
+	tests := []struct {
+		name     string
+		wallet   WalletData
+		password string
+		wantErr  error
+	}{
+		{name: "v0 without version", wallet: legacyWallet("a", 0), password: testPassword},
+		{name: "v1 legacy", wallet: legacyWallet("a", walletVersionLegacy), password: testPassword},
+		{name: "v2 argon2id", wallet: argon2Wallet("a"), password: testPassword},
+		{name: "v0 wrong password", wallet: legacyWallet("a", 0), password: "wrong", wantErr: ErrWrongPassword},
+		{name: "v2 wrong password", wallet: argon2Wallet("a"), password: "wrong", wantErr: ErrWrongPassword},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mnemonic, err := openMnemonic(&tt.wallet, tt.password)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("openMnemonic: %v", err)
+			}
+			if mnemonic != testMnemonic {
+				t.Errorf("mnemonic = %q", mnemonic)
+			}
+		})
+	}
+}
+
+func TestSealMnemonicRoundTrip(t *testing.T) {
+	var w WalletData
+	if err := sealMnemonic(&w, testMnemonic, testPassword); err != nil {
+		t.Fatal(err)
+	}
+	if w.Version != walletVersionArgon2 || w.KDF == nil || *w.KDF != *defaultKDFParams() {
+		t.Fatalf("sealed with version %d and KDF %+v", w.Version, w.KDF)
+	}
+	if needsReencrypt(&w) {
+		t.Error("freshly sealed wallet needs re-encryption")
+	}
+
+	mnemonic, err := openMnemonic(&w, testPassword)
+	if err != nil || mnemonic != testMnemonic {
+		t.Fatalf("openMnemonic = %q, %v", mnemonic, err)
+	}
+	if _, err := openMnemonic(&w, "wrong"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("wrong password: err = %v", err)
+	}
+
+	// Each seal uses a fresh salt
+	salt := w.Salt
+	if err := sealMnemonic(&w, testMnemonic, testPassword); err != nil {
+		t.Fatal(err)
+	}
+	if w.Salt == salt {
+		t.Error("salt reused")
+	}
+}
+
+func TestNeedsReencrypt(t *testing.T) {
+	weak := defaultKDFParams()
+	weak.Memory = 1024
+
+	tests := []struct {
+		name   string
+		wallet WalletData
+		want   bool
+	}{
+		{name: "v0", wallet: legacyWallet("a", 0), want: true},
+		{name: "v1", wallet: legacyWallet("a", walletVersionLegacy), want: true},
+		{name: "v2 current", wallet: argon2Wallet("a"), want: false},
+		{name: "v2 without params", wallet: WalletData{Version: walletVersionArgon2}, want: true},
+		{name: "v2 weaker params", wallet: WalletData{Version: walletVersionArgon2, KDF: weak}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := needsReencrypt(&tt.wallet); got != tt.want {
+				t.Errorf("needsReencrypt = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func saveTestWallets(t *testing.T, s *Service, wallets ...WalletData) {
+	t.Helper()
+	data, _ := json.Marshal(WalletsStore{Wallets: wallets, ActiveWalletID: wallets[0].ID})
+	if err := os.WriteFile(filepath.Join(s.dataDir, "wallets.json"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReencryptWallets(t *testing.T) {
+	s := newService(t.TempDir(), &fakeRunner{})
+	// A legacy wallet under another password
+	other := legacyWallet("other", walletVersionLegacy)
+	key, _ := deriveKey("another password", testSalt, walletVersionLegacy, nil)
+	other.EncryptedMnemonic, _ = encryptData(testMnemonic, key)
+	saveTestWallets(t, s, legacyWallet("v0", 0), legacyWallet("v1", walletVersionLegacy), argon2Wallet("v2"), other)
+
+	upgraded, current, failed, err := s.ReencryptWallets(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upgraded != 2 || current != 1 || len(failed) != 1 || failed[0] != "other" {
+		t.Fatalf("upgraded %d, current %d, failed %v", upgraded, current, failed)
+	}
+
+	store, err := s.loadWalletsStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range store.Wallets[:3] {
+		if needsReencrypt(&w) {
+			t.Errorf("wallet %s still needs re-encryption", w.ID)
+		}
+		if mnemonic, err := openMnemonic(&w, testPassword); err != nil || mnemonic != testMnemonic {
+			t.Errorf("wallet %s: openMnemonic = %q, %v", w.ID, mnemonic, err)
+		}
+	}
+	if w := store.Wallets[3]; w.EncryptedMnemonic != other.EncryptedMnemonic {
+		t.Error("wallet with another password was changed")
+	}
+}
+
+func TestGetMnemonicMigratesLegacyWallet(t *testing.T) {
+	s := newService(t.TempDir(), &fakeRunner{})
+	saveTestWallets(t, s, legacyWallet("v1", walletVersionLegacy))
+
+	if _, _, err := s.getMnemonic("v1", "wrong"); !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("wrong password: err = %v", err)
+	}
+	if store, _ := s.loadWalletsStore(); store.Wallets[0].Version != walletVersionLegacy {
+		t.Fatal("wallet migrated without its password")
+	}
+
+	mnemonic, _, err := s.getMnemonic("v1", testPassword)
+	if err != nil || mnemonic != testMnemonic {
+		t.Fatalf("getMnemonic = %q, %v", mnemonic, err)
+	}
+	store, _ := s.loadWalletsStore()
+	if w := store.Wallets[0]; w.Version != walletVersionArgon2 || needsReencrypt(&w) {
+		t.Errorf("wallet not migrated: version %d, KDF %+v", w.Version, w.KDF)
+	}
+	if mnemonic, err := openMnemonic(&store.Wallets[0], testPassword); err != nil || mnemonic != testMnemonic {
+		t.Errorf("migrated wallet: openMnemonic = %q, %v", mnemonic, err)
+	}
+}
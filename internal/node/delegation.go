@@ -0,0 +1,370 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	sdkmath "cosmossdk.io/math"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/tickfy/tickfy-validator-setup/internal/tx"
+)
+
+// =============================================================================
+// VALIDATOR DIRECTORY
+// =============================================================================
+
+type ValidatorEntry struct {
+	OperatorAddress string `json:"operatorAddress"`
+	Moniker         string `json:"moniker"`
+	Website         string `json:"website,omitempty"`
+	Commission      string `json:"commission"`
+	Tokens          string `json:"tokens"`
+	VotingPower     int64  `json:"votingPower"`
+	Status          string `json:"status"`
+	Jailed          bool   `json:"jailed"`
+}
+
+// GetValidators lists every validator on chain, bonded ones first, then by
+// voting power.
+func (s *Service) GetValidators() ([]ValidatorEntry, error) {
+	var resp struct {
+		Validators []chainValidator `json:"validators"`
+	}
+	if err := s.queryChain("/cosmos/staking/v1beta1/validators?pagination.limit=1000", &resp); err != nil {
+		return nil, fmt.Errorf("erro ao consultar validadores: %v", err)
+	}
+
+	entries := make([]ValidatorEntry, 0, len(resp.Validators))
+	tokens := make(map[string]sdkmath.Int, len(resp.Validators))
+	for _, v := range resp.Validators {
+		amount, ok := sdkmath.NewIntFromString(v.Tokens)
+		if !ok {
+			amount = sdkmath.ZeroInt()
+		}
+		tokens[v.OperatorAddress] = amount
+		entries = append(entries, ValidatorEntry{
+			OperatorAddress: v.OperatorAddress,
+			Moniker:         v.Description.Moniker,
+			Website:         v.Description.Website,
+			Commission:      v.Commission.CommissionRates.Rate,
+			Tokens:          amount.String(),
+			VotingPower:     sdk.TokensToConsensusPower(amount, sdk.DefaultPowerReduction),
+			Status:          bondStatuses[v.Status],
+			Jailed:          v.Jailed,
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		bi, bj := entries[i].Status == "bonded", entries[j].Status == "bonded"
+		if bi != bj {
+			return bi
+		}
+		return tokens[entries[i].OperatorAddress].GT(tokens[entries[j].OperatorAddress])
+	})
+	return entries, nil
+}
+
+// =============================================================================
+// DELEGATIONS
+// =============================================================================
+
+type Delegation struct {
+	Validator string `json:"validator"`
+	Moniker   string `json:"moniker"`
+	Amount    string `json:"amount"`
+	Rewards   string `json:"rewards"`
+}
+
+type Unbonding struct {
+	Validator      string `json:"validator"`
+	Amount         string `json:"amount"`
+	CompletionTime string `json:"completionTime"`
+}
+
+type Delegations struct {
+	Address     string       `json:"address"`
+	Denom       string       `json:"denom"`
+	Delegations []Delegation `json:"delegations"`
+	Unbondings  []Unbonding  `json:"unbondings"`
+}
+
+type DelegationResult struct {
+	Tx *tx.Result `json:"tx"`
+	// CompletionTime is when undelegated or redelegated tokens are released.
+	CompletionTime string `json:"completionTime,omitempty"`
+	// CompletionEstimated is set while the tx is not in a block yet, when
+	// CompletionTime is now plus the unbonding time.
+	CompletionEstimated bool `json:"completionEstimated,omitempty"`
+}
+
+// GetDelegations lists the delegations and unbondings of the active wallet.
+func (s *Service) GetDelegations() (*Delegations, error) {
+	address, _, err := s.GetWalletInfo()
+	if err != nil {
+		return nil, err
+	}
+	denom, err := s.getBondDenom()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Delegations{
+		Address:     address,
+		Denom:       denom,
+		Delegations: []Delegation{},
+		Unbondings:  []Unbonding{},
+	}
+
+	var delResp struct {
+		DelegationResponses []struct {
+			Delegation struct {
+				ValidatorAddress string `json:"validator_address"`
+			} `json:"delegation"`
+			Balance decCoin `json:"balance"`
+		} `json:"delegation_responses"`
+	}
+	if err := s.queryChain("/cosmos/staking/v1beta1/delegations/"+address+"?pagination.limit=1000", &delResp); err != nil {
+		return nil, fmt.Errorf("erro ao consultar delegações: %v", err)
+	}
+
+	var rewardsResp struct {
+		Rewards []struct {
+			ValidatorAddress string    `json:"validator_address"`
+			Reward           []decCoin `json:"reward"`
+		} `json:"rewards"`
+	}
+	rewards := make(map[string]sdkmath.Int)
+	if err := s.queryChain("/cosmos/distribution/v1beta1/delegators/"+address+"/rewards", &rewardsResp); err == nil {
+		for _, r := range rewardsResp.Rewards {
+			rewards[r.ValidatorAddress] = amountOf(r.Reward, denom)
+		}
+	}
+
+	monikers := make(map[string]string)
+	if validators, err := s.GetValidators(); err == nil {
+		for _, v := range validators {
+			monikers[v.OperatorAddress] = v.Moniker
+		}
+	}
+
+	for _, d := range delResp.DelegationResponses {
+		val := d.Delegation.ValidatorAddress
+		reward, ok := rewards[val]
+		if !ok {
+			reward = sdkmath.ZeroInt()
+		}
+		result.Delegations = append(result.Delegations, Delegation{
+			Validator: val,
+			Moniker:   monikers[val],
+			Amount:    amountOf([]decCoin{d.Balance}, denom).String(),
+			Rewards:   reward.String(),
+		})
+	}
+
+	var ubdResp struct {
+		UnbondingResponses []struct {
+			ValidatorAddress string `json:"validator_address"`
+			Entries          []struct {
+				CompletionTime string `json:"completion_time"`
+				Balance        string `json:"balance"`
+			} `json:"entries"`
+		} `json:"unbonding_responses"`
+	}
+	if err := s.queryChain("/cosmos/staking/v1beta1/delegators/"+address+"/unbonding_delegations?pagination.limit=1000", &ubdResp); err != nil {
+		return nil, fmt.Errorf("erro ao consultar unbondings: %v", err)
+	}
+	for _, ubd := range ubdResp.UnbondingResponses {
+		for _, entry := range ubd.Entries {
+			result.Unbondings = append(result.Unbondings, Unbonding{
+				Validator:      ubd.ValidatorAddress,
+				Amount:         entry.Balance,
+				CompletionTime: entry.CompletionTime,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// delegatedTo returns how much delegator has delegated to validator.
+func (s *Service) delegatedTo(delegator, validator, denom string) (sdkmath.Int, error) {
+	var resp struct {
+		DelegationResponse struct {
+			Balance decCoin `json:"balance"`
+		} `json:"delegation_response"`
+	}
+	err := s.queryChain(fmt.Sprintf("/cosmos/staking/v1beta1/validators/%s/delegations/%s", validator, delegator), &resp)
+	if errors.Is(err, tx.ErrNotFound) {
+		return sdkmath.ZeroInt(), nil
+	}
+	if err != nil {
+		return sdkmath.ZeroInt(), fmt.Errorf("erro ao consultar delegação: %v", err)
+	}
+	return amountOf([]decCoin{resp.DelegationResponse.Balance}, denom), nil
+}
+
+// completionTime reads when unbonded or redelegated tokens are released
+// from the message response in the tx result. It is empty while the tx is
+// not in a block yet.
+func completionTime(result *tx.Result) string {
+	responses, err := result.MsgResponses()
+	if err != nil {
+		return ""
+	}
+	for _, response := range responses {
+		switch r := response.(type) {
+		case *stakingtypes.MsgUndelegateResponse:
+			return r.CompletionTime.UTC().Format(time.RFC3339)
+		case *stakingtypes.MsgBeginRedelegateResponse:
+			return r.CompletionTime.UTC().Format(time.RFC3339)
+		}
+	}
+	return ""
+}
+
+// delegationResult returns result with its completion time, estimated from
+// the unbonding time while the tx is not in a block yet.
+func (s *Service) delegationResult(result *tx.Result) *DelegationResult {
+	r := &DelegationResult{Tx: result, CompletionTime: completionTime(result)}
+	if r.CompletionTime == "" {
+		r.CompletionEstimated = true
+		if unbonding, err := s.getUnbondingTime(); err == nil {
+			r.CompletionTime = time.Now().Add(unbonding).UTC().Format(time.RFC3339)
+		}
+	}
+	return r
+}
+
+// parseDelegationAmount validates amount against the validator it goes to
+// and returns it with the bond denom.
+func (s *Service) parseDelegationAmount(amount string, validators ...string) (sdk.Coin, error) {
+	value, ok := sdkmath.NewIntFromString(amount)
+	if !ok || !value.IsPositive() {
+		return sdk.Coin{}, errors.New("valor inválido")
+	}
+	for _, v := range validators {
+		if err := tx.ValidateAddress(v, tx.ValidatorPrefix); err != nil {
+			return sdk.Coin{}, fmt.Errorf("validador inválido: %v", err)
+		}
+	}
+	denom, err := s.getBondDenom()
+	if err != nil {
+		return sdk.Coin{}, err
+	}
+	return sdk.NewCoin(denom, value), nil
+}
+
+// Delegate delegates amount, in base units of the bond denom, from the
+// wallet to validator.
+func (s *Service) Delegate(password, walletID, validator, amount string) (*DelegationResult, error) {
+	coin, err := s.parseDelegationAmount(amount, validator)
+	if err != nil {
+		return nil, err
+	}
+	key, _, err := s.unlockKey(walletID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.queryValidator(validator); err != nil {
+		return nil, err
+	}
+
+	var balance struct {
+		Balance decCoin `json:"balance"`
+	}
+	if err := s.queryChain(fmt.Sprintf("/cosmos/bank/v1beta1/balances/%s/by_denom?denom=%s", key.Address(), coin.Denom), &balance); err != nil {
+		return nil, fmt.Errorf("erro ao consultar saldo: %v", err)
+	}
+	if available := amountOf([]decCoin{balance.Balance}, coin.Denom); available.LT(coin.Amount) {
+		return nil, fmt.Errorf("saldo insuficiente: %s disponível", sdk.NewCoin(coin.Denom, available))
+	}
+
+	msg := stakingtypes.NewMsgDelegate(key.Address(), validator, coin)
+	result, err := s.sendTx(key, []sdk.Msg{msg}, "")
+	if result == nil {
+		return nil, fmt.Errorf("erro ao delegar: %v", err)
+	}
+	if err != nil {
+		return &DelegationResult{Tx: result}, fmt.Errorf("erro ao delegar: %v", err)
+	}
+
+	s.addLog(fmt.Sprintf("Delegated %s to %s (tx %s)", coin, validator, result.TxHash))
+	return &DelegationResult{Tx: result}, nil
+}
+
+// Undelegate starts unbonding amount from validator. The tokens are
+// released at the returned completion time.
+func (s *Service) Undelegate(password, walletID, validator, amount string) (*DelegationResult, error) {
+	coin, err := s.parseDelegationAmount(amount, validator)
+	if err != nil {
+		return nil, err
+	}
+	key, _, err := s.unlockKey(walletID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	delegated, err := s.delegatedTo(key.Address(), validator, coin.Denom)
+	if err != nil {
+		return nil, err
+	}
+	if delegated.LT(coin.Amount) {
+		return nil, fmt.Errorf("delegação insuficiente: %s delegado a %s", sdk.NewCoin(coin.Denom, delegated), validator)
+	}
+
+	msg := stakingtypes.NewMsgUndelegate(key.Address(), validator, coin)
+	result, err := s.sendTx(key, []sdk.Msg{msg}, "")
+	if result == nil {
+		return nil, fmt.Errorf("erro ao remover delegação: %v", err)
+	}
+	if err != nil {
+		return &DelegationResult{Tx: result}, fmt.Errorf("erro ao remover delegação: %v", err)
+	}
+
+	r := s.delegationResult(result)
+	s.addLog(fmt.Sprintf("Undelegated %s from %s, available at %s (tx %s)", coin, validator, r.CompletionTime, result.TxHash))
+	return r, nil
+}
+
+// Redelegate moves amount from one validator to another without unbonding.
+// The moved tokens cannot be redelegated again until the completion time.
+func (s *Service) Redelegate(password, walletID, srcValidator, dstValidator, amount string) (*DelegationResult, error) {
+	coin, err := s.parseDelegationAmount(amount, srcValidator, dstValidator)
+	if err != nil {
+		return nil, err
+	}
+	if srcValidator == dstValidator {
+		return nil, errors.New("validador de origem e destino são o mesmo")
+	}
+	key, _, err := s.unlockKey(walletID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.queryValidator(dstValidator); err != nil {
+		return nil, fmt.Errorf("validador de destino: %v", err)
+	}
+	delegated, err := s.delegatedTo(key.Address(), srcValidator, coin.Denom)
+	if err != nil {
+		return nil, err
+	}
+	if delegated.LT(coin.Amount) {
+		return nil, fmt.Errorf("delegação insuficiente: %s delegado a %s", sdk.NewCoin(coin.Denom, delegated), srcValidator)
+	}
+
+	msg := stakingtypes.NewMsgBeginRedelegate(key.Address(), srcValidator, dstValidator, coin)
+	result, err := s.sendTx(key, []sdk.Msg{msg}, "")
+	if result == nil {
+		return nil, fmt.Errorf("erro ao redelegar: %v", err)
+	}
+	if err != nil {
+		return &DelegationResult{Tx: result}, fmt.Errorf("erro ao redelegar: %v", err)
+	}
+
+	s.addLog(fmt.Sprintf("Redelegated %s from %s to %s (tx %s)", coin, srcValidator, dstValidator, result.TxHash))
+	return s.delegationResult(result), nil
+}
@@ -0,0 +1,322 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	sdkmath "cosmossdk.io/math"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	govv1 "github.com/cosmos/cosmos-sdk/x/gov/types/v1"
+	"github.com/tickfy/tickfy-validator-setup/internal/tx"
+)
+
+// =============================================================================
+// GOVERNANCE
+// =============================================================================
+
+const (
+	proposalVotingPeriod = "PROPOSAL_STATUS_VOTING_PERIOD"
+	// pendingVotesTTL is how long the pending votes count in AppStatus is
+	// cached, since computing it takes a query per open proposal.
+	pendingVotesTTL = time.Minute
+)
+
+type Tally struct {
+	Yes        string `json:"yes"`
+	No         string `json:"no"`
+	Abstain    string `json:"abstain"`
+	NoWithVeto string `json:"noWithVeto"`
+}
+
+type VoteOption struct {
+	Option string `json:"option"`
+	Weight string `json:"weight"`
+}
+
+type Proposal struct {
+	ID              uint64       `json:"id"`
+	Title           string       `json:"title"`
+	Summary         string       `json:"summary"`
+	Status          string       `json:"status"`
+	Messages        []string     `json:"messages"`
+	Proposer        string       `json:"proposer,omitempty"`
+	Expedited       bool         `json:"expedited"`
+	SubmitTime      string       `json:"submitTime"`
+	VotingStartTime string       `json:"votingStartTime,omitempty"`
+	VotingEndTime   string       `json:"votingEndTime,omitempty"`
+	Tally           Tally        `json:"tally"`
+	Voted           bool         `json:"voted"`
+	Vote            []VoteOption `json:"vote,omitempty"`
+	// VoteError is set when the wallet's vote could not be looked up, in
+	// which case Voted means nothing.
+	VoteError string `json:"voteError,omitempty"`
+}
+
+type ProposalPage struct {
+	Proposals []Proposal `json:"proposals"`
+	Total     int        `json:"total"`
+}
+
+// chainProposal is a gov v1 proposal as the REST API returns it.
+type chainProposal struct {
+	ID       uint64 `json:"id,string"`
+	Messages []struct {
+		Type string `json:"@type"`
+	} `json:"messages"`
+	Status          string     `json:"status"`
+	FinalTally      chainTally `json:"final_tally_result"`
+	SubmitTime      string     `json:"submit_time"`
+	VotingStartTime string     `json:"voting_start_time"`
+	VotingEndTime   string     `json:"voting_end_time"`
+	Title           string     `json:"title"`
+	Summary         string     `json:"summary"`
+	Proposer        string     `json:"proposer"`
+	Expedited       bool       `json:"expedited"`
+}
+
+type chainTally struct {
+	Yes        string `json:"yes_count"`
+	Abstain    string `json:"abstain_count"`
+	No         string `json:"no_count"`
+	NoWithVeto string `json:"no_with_veto_count"`
+}
+
+func (t chainTally) tally() Tally {
+	return Tally{Yes: t.Yes, No: t.No, Abstain: t.Abstain, NoWithVeto: t.NoWithVeto}
+}
+
+func (p chainProposal) proposal() Proposal {
+	proposal := Proposal{
+		ID:              p.ID,
+		Title:           p.Title,
+		Summary:         p.Summary,
+		Status:          strings.ToLower(strings.TrimPrefix(p.Status, "PROPOSAL_STATUS_")),
+		Messages:        []string{},
+		Proposer:        p.Proposer,
+		Expedited:       p.Expedited,
+		SubmitTime:      p.SubmitTime,
+		VotingStartTime: p.VotingStartTime,
+		VotingEndTime:   p.VotingEndTime,
+		Tally:           p.FinalTally.tally(),
+	}
+	for _, m := range p.Messages {
+		proposal.Messages = append(proposal.Messages, msgTypeName(m.Type))
+	}
+	return proposal
+}
+
+// queryVote returns the vote of voter on a proposal, or nil if there is none.
+func (s *Service) queryVote(id uint64, voter string) ([]VoteOption, error) {
+	var resp struct {
+		Vote struct {
+			Options []struct {
+				Option string `json:"option"`
+				Weight string `json:"weight"`
+			} `json:"options"`
+		} `json:"vote"`
+	}
+	err := s.queryChain(fmt.Sprintf("/cosmos/gov/v1/proposals/%d/votes/%s", id, voter), &resp)
+	// The gov module answers InvalidArgument rather than NotFound
+	if err != nil && (errors.Is(err, tx.ErrNotFound) || strings.Contains(err.Error(), "not found")) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao consultar voto: %v", err)
+	}
+
+	var options []VoteOption
+	for _, o := range resp.Vote.Options {
+		options = append(options, VoteOption{
+			Option: strings.ToLower(strings.TrimPrefix(o.Option, "VOTE_OPTION_")),
+			Weight: o.Weight,
+		})
+	}
+	return options, nil
+}
+
+// GetProposals lists proposals, newest first. With active only those in
+// the voting period are returned, each with the active wallet's vote or the
+// error looking it up.
+func (s *Service) GetProposals(active bool, limit int) (*ProposalPage, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	path := fmt.Sprintf("/cosmos/gov/v1/proposals?pagination.limit=%d&pagination.reverse=true&pagination.count_total=true", limit)
+	if active {
+		path += "&proposal_status=" + proposalVotingPeriod
+	}
+	var resp struct {
+		Proposals  []chainProposal `json:"proposals"`
+		Pagination struct {
+			Total int `json:"total,string"`
+		} `json:"pagination"`
+	}
+	if err := s.queryChain(path, &resp); err != nil {
+		return nil, fmt.Errorf("erro ao consultar propostas: %v", err)
+	}
+
+	voter, _, _ := s.GetWalletInfo()
+	page := &ProposalPage{Proposals: []Proposal{}, Total: resp.Pagination.Total}
+	for _, p := range resp.Proposals {
+		proposal := p.proposal()
+		if voter != "" && p.Status == proposalVotingPeriod {
+			vote, err := s.queryVote(p.ID, voter)
+			if err != nil {
+				proposal.VoteError = err.Error()
+			}
+			proposal.Voted = vote != nil
+			proposal.Vote = vote
+		}
+		page.Proposals = append(page.Proposals, proposal)
+	}
+	return page, nil
+}
+
+// GetProposal returns a proposal with its current tally and the active
+// wallet's vote.
+func (s *Service) GetProposal(id uint64) (*Proposal, error) {
+	var resp struct {
+		Proposal chainProposal `json:"proposal"`
+	}
+	if err := s.queryChain(fmt.Sprintf("/cosmos/gov/v1/proposals/%d", id), &resp); err != nil {
+		if errors.Is(err, tx.ErrNotFound) || strings.Contains(err.Error(), "doesn't exist") {
+			return nil, fmt.Errorf("proposta %d não encontrada", id)
+		}
+		return nil, fmt.Errorf("erro ao consultar proposta: %v", err)
+	}
+	proposal := resp.Proposal.proposal()
+
+	// The final tally is only filled in once voting ends
+	if resp.Proposal.Status == proposalVotingPeriod {
+		var tallyResp struct {
+			Tally chainTally `json:"tally"`
+		}
+		if err := s.queryChain(fmt.Sprintf("/cosmos/gov/v1/proposals/%d/tally", id), &tallyResp); err == nil {
+			proposal.Tally = tallyResp.Tally.tally()
+		}
+	}
+
+	if voter, _, err := s.GetWalletInfo(); err == nil {
+		vote, err := s.queryVote(id, voter)
+		if err != nil {
+			return nil, err
+		}
+		proposal.Voted = vote != nil
+		proposal.Vote = vote
+	}
+	return &proposal, nil
+}
+
+func parseVoteOption(option string) (govv1.VoteOption, error) {
+	name := strings.ToUpper(strings.TrimSpace(option))
+	if !strings.HasPrefix(name, "VOTE_OPTION_") {
+		name = "VOTE_OPTION_" + name
+	}
+	value, err := govv1.VoteOptionFromString(name)
+	if err != nil || !govv1.ValidVoteOption(value) {
+		return govv1.OptionEmpty, fmt.Errorf("opção de voto inválida: %q (use yes, no, abstain ou no_with_veto)", option)
+	}
+	return value, nil
+}
+
+// Vote votes on a proposal with the wallet. A single option is a plain
+// vote; several options are a weighted vote whose weights must add up to 1.
+func (s *Service) Vote(password, walletID string, id uint64, options []VoteOption, metadata string) (*tx.Result, error) {
+	if len(options) == 0 {
+		return nil, errors.New("informe a opção de voto")
+	}
+
+	weighted := make([]*govv1.WeightedVoteOption, 0, len(options))
+	total := sdkmath.LegacyZeroDec()
+	seen := make(map[govv1.VoteOption]bool)
+	for _, o := range options {
+		option, err := parseVoteOption(o.Option)
+		if err != nil {
+			return nil, err
+		}
+		if seen[option] {
+			return nil, fmt.Errorf("opção de voto repetida: %s", o.Option)
+		}
+		seen[option] = true
+
+		weight := sdkmath.LegacyOneDec()
+		if o.Weight != "" {
+			if weight, err = sdkmath.LegacyNewDecFromStr(o.Weight); err != nil || !weight.IsPositive() || weight.GT(sdkmath.LegacyOneDec()) {
+				return nil, fmt.Errorf("peso inválido para %s: %s", o.Option, o.Weight)
+			}
+		}
+		total = total.Add(weight)
+		weighted = append(weighted, govv1.NewWeightedVoteOption(option, weight))
+	}
+	if !total.Equal(sdkmath.LegacyOneDec()) {
+		return nil, fmt.Errorf("os pesos do voto devem somar 1 (somam %s)", total)
+	}
+
+	proposal, err := s.GetProposal(id)
+	if err != nil {
+		return nil, err
+	}
+	if proposal.Status != "voting_period" {
+		return nil, fmt.Errorf("a proposta %d não está em votação (%s)", id, proposal.Status)
+	}
+
+	key, _, err := s.unlockKey(walletID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg sdk.Msg
+	if len(weighted) == 1 {
+		msg = &govv1.MsgVote{ProposalId: id, Voter: key.Address(), Option: weighted[0].Option, Metadata: metadata}
+	} else {
+		msg = &govv1.MsgVoteWeighted{ProposalId: id, Voter: key.Address(), Options: weighted, Metadata: metadata}
+	}
+
+	result, err := s.sendTx(key, []sdk.Msg{msg}, "")
+	if err != nil {
+		return result, fmt.Errorf("erro ao votar: %v", err)
+	}
+
+	s.govMutex.Lock()
+	s.pendingVotesAt = time.Time{}
+	s.govMutex.Unlock()
+
+	s.addLog(fmt.Sprintf("Voted on proposal %d (tx %s)", id, result.TxHash))
+	return result, nil
+}
+
+// pendingVotes returns how many proposals in voting period the active
+// wallet has not voted on, leaving out those whose vote could not be
+// looked up. It answers from a cache and refreshes it in the
+// background once stale.
+func (s *Service) pendingVotes() int {
+	s.govMutex.Lock()
+	defer s.govMutex.Unlock()
+
+	if time.Since(s.pendingVotesAt) > pendingVotesTTL && !s.pendingVotesBusy {
+		s.pendingVotesBusy = true
+		go func() {
+			count := -1
+			if page, err := s.GetProposals(true, 200); err == nil {
+				count = 0
+				for _, p := range page.Proposals {
+					if !p.Voted && p.VoteError == "" {
+						count++
+					}
+				}
+			}
+
+			s.govMutex.Lock()
+			if count >= 0 {
+				s.pendingVotesCount = count
+			}
+			s.pendingVotesAt = time.Now()
+			s.pendingVotesBusy = false
+			s.govMutex.Unlock()
+		}()
+	}
+	return s.pendingVotesCount
+}
@@ -0,0 +1,400 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// JOBS
+// =============================================================================
+
+const (
+	JobInstallNode       = "node-install"
+	JobInstallCosmovisor = "cosmovisor-install"
+	JobPrepareUpgrade    = "upgrade-prepare"
+	JobInstallVersion    = "version-install"
+)
+
+type JobStatus string
+
+const (
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCanceled  JobStatus = "canceled"
+)
+
+// maxFinishedJobs bounds how many finished jobs are kept for listing.
+const maxFinishedJobs = 50
+
+type JobInfo struct {
+	ID         string    `json:"id"`
+	Kind       string    `json:"kind"`
+	Status     JobStatus `json:"status"`
+	Progress   int       `json:"progress"`
+	Downloaded int64     `json:"downloaded"`
+	Total      int64     `json:"total"`
+	Logs       []string  `json:"logs"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  int64     `json:"createdAt"`
+	FinishedAt int64     `json:"finishedAt,omitempty"`
+}
+
+type JobEvent struct {
+	Type       string    `json:"type"` // "progress", "log" or "done"
+	Progress   int       `json:"progress"`
+	Downloaded int64     `json:"downloaded"`
+	Total      int64     `json:"total"`
+	Message    string    `json:"message,omitempty"`
+	Status     JobStatus `json:"status"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Job tracks a long running operation. A nil *Job is valid and discards
+// every update, so install functions can run with or without one.
+type Job struct {
+	mu          sync.Mutex
+	info        JobInfo
+	cancel      context.CancelFunc
+	subscribers map[chan JobEvent]struct{}
+}
+
+func (j *Job) Info() JobInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	info := j.info
+	info.Logs = append([]string(nil), j.info.Logs...)
+	return info
+}
+
+func (j *Job) SetProgress(downloaded, total int64) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.info.Downloaded = downloaded
+	j.info.Total = total
+	if total > 0 {
+		j.info.Progress = int(float64(downloaded) / float64(total) * 100)
+	}
+	j.publish(JobEvent{Type: "progress"})
+}
+
+func (j *Job) Log(msg string) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry := fmt.Sprintf("[%s] %s", time.Now().Format("15:04:05"), msg)
+	j.info.Logs = append(j.info.Logs, entry)
+	j.publish(JobEvent{Type: "log", Message: entry})
+}
+
+func (j *Job) finish(err error, canceled bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	switch {
+	case canceled:
+		j.info.Status = JobCanceled
+		j.info.Error = "cancelado pelo usuário"
+	case err != nil:
+		j.info.Status = JobFailed
+		j.info.Error = err.Error()
+	default:
+		j.info.Status = JobSucceeded
+		j.info.Progress = 100
+	}
+	j.info.FinishedAt = time.Now().Unix()
+
+	j.publish(JobEvent{Type: "done"})
+	for ch := range j.subscribers {
+		close(ch)
+	}
+	j.subscribers = nil
+}
+
+// publish must be called with j.mu held. Slow subscribers miss progress
+// events rather than blocking the download.
+func (j *Job) publish(ev JobEvent) {
+	ev.Progress = j.info.Progress
+	ev.Downloaded = j.info.Downloaded
+	ev.Total = j.info.Total
+	ev.Status = j.info.Status
+	ev.Error = j.info.Error
+
+	for ch := range j.subscribers {
+		if ev.Type == "done" {
+			// Make room so the final event is never lost
+			select {
+			case <-ch:
+			default:
+			}
+		}
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+func (s *Service) StartJob(kind string) (JobInfo, error) {
+	var run func(ctx context.Context, job *Job) error
+	switch kind {
+	case JobInstallNode:
+		run = s.InstallNode
+	case JobInstallCosmovisor:
+		run = s.InstallCosmovisor
+	case JobPrepareUpgrade:
+		run = s.PrepareUpgrade
+	default:
+		return JobInfo{}, errors.New("tipo de tarefa inválido")
+	}
+	return s.startJob(kind, run)
+}
+
+// StartDependencyJob installs a component of the setup wizard, "binary" or
+// "cosmovisor", as a job. Cosmovisor's directories are set up once it is
+// installed, as the wizard runs the node under it.
+func (s *Service) StartDependencyJob(component string) (JobInfo, error) {
+	switch component {
+	case "binary":
+		return s.startJob(JobInstallNode, s.InstallNode)
+	case "cosmovisor":
+		return s.startJob(JobInstallCosmovisor, func(ctx context.Context, job *Job) error {
+			if err := s.InstallCosmovisor(ctx, job); err != nil {
+				return err
+			}
+			return s.SetupCosmovisorDirs()
+		})
+	default:
+		return JobInfo{}, errors.New("componente inválido")
+	}
+}
+
+// binaryStoreJobs write to the version store, so only one of them runs at a
+// time.
+var binaryStoreJobs = map[string]bool{
+	JobInstallNode:    true,
+	JobInstallVersion: true,
+}
+
+// startJob runs run in the background as a job of the given kind, refusing
+// a second one of the same kind, or a second binary store job, while the
+// first is running.
+func (s *Service) startJob(kind string, run func(ctx context.Context, job *Job) error) (JobInfo, error) {
+	s.jobsMutex.Lock()
+	defer s.jobsMutex.Unlock()
+
+	for _, j := range s.jobs {
+		info := j.Info()
+		if info.Status != JobRunning {
+			continue
+		}
+		if info.Kind == kind {
+			return JobInfo{}, errors.New("já existe uma tarefa deste tipo em andamento")
+		}
+		if binaryStoreJobs[kind] && binaryStoreJobs[info.Kind] {
+			return JobInfo{}, errors.New("já existe uma instalação de binário em andamento")
+		}
+	}
+	s.pruneJobs()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		info: JobInfo{
+			ID:        generateWalletID(),
+			Kind:      kind,
+			Status:    JobRunning,
+			Logs:      []string{},
+			CreatedAt: time.Now().Unix(),
+		},
+		cancel:      cancel,
+		subscribers: make(map[chan JobEvent]struct{}),
+	}
+	s.jobs[job.info.ID] = job
+
+	go func() {
+		defer cancel()
+		err := run(ctx, job)
+		// A cancel that lands after run succeeded does not undo the job
+		job.finish(err, err != nil && ctx.Err() != nil)
+	}()
+
+	return job.Info(), nil
+}
+
+// pruneJobs must be called with s.jobsMutex held.
+func (s *Service) pruneJobs() {
+	var finished []JobInfo
+	for _, j := range s.jobs {
+		if info := j.Info(); info.Status != JobRunning {
+			finished = append(finished, info)
+		}
+	}
+	if len(finished) < maxFinishedJobs {
+		return
+	}
+
+	sort.Slice(finished, func(a, b int) bool {
+		return finished[a].FinishedAt < finished[b].FinishedAt
+	})
+	for _, info := range finished[:len(finished)-maxFinishedJobs+1] {
+		delete(s.jobs, info.ID)
+	}
+}
+
+func (s *Service) GetJobs() []JobInfo {
+	s.jobsMutex.Lock()
+	defer s.jobsMutex.Unlock()
+
+	jobs := make([]JobInfo, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j.Info())
+	}
+	sort.Slice(jobs, func(a, b int) bool {
+		return jobs[a].CreatedAt > jobs[b].CreatedAt
+	})
+	return jobs
+}
+
+func (s *Service) getJob(id string) (*Job, error) {
+	s.jobsMutex.Lock()
+	defer s.jobsMutex.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, errors.New("tarefa não encontrada")
+	}
+	return job, nil
+}
+
+func (s *Service) GetJob(id string) (JobInfo, error) {
+	job, err := s.getJob(id)
+	if err != nil {
+		return JobInfo{}, err
+	}
+	return job.Info(), nil
+}
+
+func (s *Service) CancelJob(id string) error {
+	job, err := s.getJob(id)
+	if err != nil {
+		return err
+	}
+	if job.Info().Status != JobRunning {
+		return errors.New("tarefa já finalizada")
+	}
+	job.cancel()
+	return nil
+}
+
+// SubscribeJob returns the current state of the job and a channel with every
+// subsequent event. The channel is closed once the job finishes; for jobs
+// that are already finished it is returned closed.
+func (s *Service) SubscribeJob(id string) (JobInfo, <-chan JobEvent, func(), error) {
+	job, err := s.getJob(id)
+	if err != nil {
+		return JobInfo{}, nil, nil, err
+	}
+
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	info := job.info
+	info.Logs = append([]string(nil), job.info.Logs...)
+
+	ch := make(chan JobEvent, 64)
+	if info.Status != JobRunning {
+		close(ch)
+		return info, ch, func() {}, nil
+	}
+
+	job.subscribers[ch] = struct{}{}
+	unsubscribe := func() {
+		job.mu.Lock()
+		defer job.mu.Unlock()
+		if _, ok := job.subscribers[ch]; ok {
+			delete(job.subscribers, ch)
+			close(ch)
+		}
+	}
+	return info, ch, unsubscribe, nil
+}
+
+// downloadFile streams url into dest, reporting progress to job. The data is
+// written to a temporary file first, so an aborted download never leaves a
+// partial file at dest.
+func (s *Service) downloadFile(ctx context.Context, url, dest string, job *Job) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("erro ao baixar: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("arquivo não encontrado (status %d)", resp.StatusCode)
+	}
+
+	tmpPath := dest + ".part"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	totalSize := resp.ContentLength
+	var downloaded int64
+	buf := make([]byte, 32*1024)
+	for {
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 {
+			if _, err := out.Write(buf[:n]); err != nil {
+				out.Close()
+				os.Remove(tmpPath)
+				return err
+			}
+			downloaded += int64(n)
+			job.SetProgress(downloaded, totalSize)
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			out.Close()
+			os.Remove(tmpPath)
+			if ctx.Err() != nil {
+				return errors.New("download cancelado")
+			}
+			return readErr
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, dest)
+}
+
+// logStep writes msg to the node log and, when set, to the job log.
+func (s *Service) logStep(job *Job, msg string) {
+	s.addLog(msg)
+	job.Log(msg)
+}
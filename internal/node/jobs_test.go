@@ -0,0 +1,63 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestJobCancel(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(ctx context.Context, cancel func()) error
+		want JobStatus
+	}{
+		{
+			name: "canceled while running",
+			run: func(ctx context.Context, cancel func()) error {
+				cancel()
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			want: JobCanceled,
+		},
+		{
+			name: "canceled after finishing",
+			run: func(ctx context.Context, cancel func()) error {
+				cancel()
+				return nil
+			},
+			want: JobSucceeded,
+		},
+		{
+			name: "failed",
+			run: func(ctx context.Context, cancel func()) error {
+				return errors.New("download failed")
+			},
+			want: JobFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newService(t.TempDir(), &fakeRunner{})
+			info, err := s.startJob(JobInstallNode, func(ctx context.Context, job *Job) error {
+				return tt.run(ctx, func() { s.CancelJob(job.Info().ID) })
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, events, unsubscribe, err := s.SubscribeJob(info.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer unsubscribe()
+			for range events {
+			}
+
+			if got, _ := s.GetJob(info.ID); got.Status != tt.want {
+				t.Errorf("status = %s (%s), want %s", got.Status, got.Error, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	cryptocodec "github.com/cosmos/cosmos-sdk/crypto/codec"
+	"github.com/cosmos/cosmos-sdk/crypto/keyring"
+	"github.com/cosmos/cosmos-sdk/types/bech32"
+	"github.com/tickfy/tickfy-validator-setup/internal/tx"
+)
+
+// =============================================================================
+// TEST KEYRING MIGRATION
+// =============================================================================
+
+var ErrKeyringPassword = errors.New("o keyring de teste tem chaves de fora das carteiras; informe a senha para exportá-las")
+
+// KeyringMigration reports what migrateTestKeyring did.
+type KeyringMigration struct {
+	Dropped  []string `json:"dropped"`  // addresses of wallets we hold
+	Exported []string `json:"exported"` // armor files written
+	Wiped    bool     `json:"wiped"`
+}
+
+func (s *Service) testKeyringDir() string {
+	return filepath.Join(s.getNodeHome(), "keyring-test")
+}
+
+// TestKeyringPending tells whether the unencrypted test keyring is still on
+// disk.
+func (s *Service) TestKeyringPending() bool {
+	_, err := os.Stat(s.testKeyringDir())
+	return err == nil
+}
+
+// MigrateTestKeyring runs migrateTestKeyring once the password has opened
+// the wallet, so keys are exported under a password the user knows.
+func (s *Service) MigrateTestKeyring(walletID, password string) (*KeyringMigration, error) {
+	if _, _, err := s.getMnemonic(walletID, password); err != nil {
+		return nil, err
+	}
+	return s.migrateTestKeyring(password)
+}
+
+// migrateStartupKeyring migrates the test keyring at startup when that needs
+// no password, and otherwise leaves it for MigrateTestKeyring.
+func (s *Service) migrateStartupKeyring() {
+	if !s.TestKeyringPending() {
+		return
+	}
+	if _, err := s.migrateTestKeyring(""); err != nil {
+		s.addLog(fmt.Sprintf("Unencrypted test keyring kept: %v", err))
+	}
+}
+
+// migrateTestKeyring moves keys out of the unencrypted "test" keyring that
+// older versions left in the node home; signing now derives the key from
+// the wallet mnemonic in process. Keys of wallets we hold are simply
+// dropped, any other key is exported as an armored file encrypted with
+// password to keys/ in the data dir, which fails with ErrKeyringPassword
+// when password is empty. The keyring is wiped afterwards.
+func (s *Service) migrateTestKeyring(password string) (*KeyringMigration, error) {
+	s.keyringMutex.Lock()
+	defer s.keyringMutex.Unlock()
+
+	result := &KeyringMigration{Dropped: []string{}, Exported: []string{}}
+	testDir := s.testKeyringDir()
+	if _, err := os.Stat(testDir); err != nil {
+		return result, nil
+	}
+
+	registry := codectypes.NewInterfaceRegistry()
+	cryptocodec.RegisterInterfaces(registry)
+	kr, err := keyring.New("tickfy", keyring.BackendTest, s.getNodeHome(), nil, codec.NewProtoCodec(registry))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir keyring de teste: %v", err)
+	}
+
+	records, err := kr.List()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler keyring de teste: %v", err)
+	}
+
+	known := make(map[string]bool)
+	if store, err := s.loadWalletsStore(); err == nil {
+		for _, w := range store.Wallets {
+			known[w.Address] = true
+		}
+	}
+
+	var foreign []string
+	for _, record := range records {
+		addr, err := record.GetAddress()
+		if err != nil {
+			continue
+		}
+		bech, _ := bech32.ConvertAndEncode(tx.AccountPrefix, addr)
+		if known[bech] {
+			result.Dropped = append(result.Dropped, bech)
+		} else {
+			foreign = append(foreign, record.Name)
+		}
+	}
+	if len(foreign) > 0 && password == "" {
+		return nil, ErrKeyringPassword
+	}
+
+	for _, name := range foreign {
+		// Keep the keyring rather than lose a key we could not export
+		armor, err := kr.ExportPrivKeyArmor(name, password)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao exportar chave %s: %v", name, err)
+		}
+		keysDir := filepath.Join(s.dataDir, "keys")
+		os.MkdirAll(keysDir, 0700)
+		armorPath := filepath.Join(keysDir, name+".armor")
+		if err := os.WriteFile(armorPath, []byte(armor), 0600); err != nil {
+			return nil, fmt.Errorf("erro ao exportar chave %s: %v", name, err)
+		}
+		result.Exported = append(result.Exported, armorPath)
+		s.addLog(fmt.Sprintf("Exported key %s from the test keyring to %s", name, armorPath))
+	}
+
+	wipeDir(testDir)
+	result.Wiped = true
+	s.addLog(fmt.Sprintf("Unencrypted test keyring wiped (%d key(s) of our wallets dropped, %d exported)", len(result.Dropped), len(result.Exported)))
+	return result, nil
+}
+
+// wipeDir overwrites the files under dir with zeros before removing it.
+func wipeDir(dir string) {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+			if f, err := os.OpenFile(path, os.O_WRONLY, 0); err == nil {
+				f.Write(make([]byte, info.Size()))
+				f.Sync()
+				f.Close()
+			}
+		}
+		return nil
+	})
+	os.RemoveAll(dir)
+}
@@ -0,0 +1,327 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/tickfy/tickfy-validator-setup/internal/tx"
+)
+
+// =============================================================================
+// TX LEDGER
+// =============================================================================
+
+const (
+	TxPending   = "pending"
+	TxConfirmed = "confirmed"
+	TxFailed    = "failed"
+	TxTimeout   = "timeout"
+
+	ledgerPollInterval = 10 * time.Second
+	// ledgerTxTimeout is how long a tx may stay out of a block before it is
+	// considered dropped from the mempool.
+	ledgerTxTimeout  = 10 * time.Minute
+	maxLedgerRecords = 5000
+
+	importPageSize = 100
+	importMaxPages = 10
+)
+
+// TxRecord is a transaction in tx-ledger.json, either submitted by the tool
+// (Source "tool") or imported from the chain (Source "chain").
+type TxRecord struct {
+	Hash        string   `json:"hash"`
+	Type        string   `json:"type"`
+	Messages    []string `json:"messages"`
+	Wallet      string   `json:"wallet"`
+	Status      string   `json:"status"`
+	Height      int64    `json:"height"`
+	Code        uint32   `json:"code"`
+	Codespace   string   `json:"codespace,omitempty"`
+	RawLog      string   `json:"rawLog,omitempty"`
+	Fee         string   `json:"fee,omitempty"`
+	Memo        string   `json:"memo,omitempty"`
+	Source      string   `json:"source"`
+	SubmittedAt int64    `json:"submittedAt"`
+	UpdatedAt   int64    `json:"updatedAt"`
+}
+
+type TxQuery struct {
+	Status string
+	Type   string
+	Wallet string
+	Page   int
+	Limit  int
+}
+
+type TxPage struct {
+	Records []TxRecord `json:"records"`
+	Total   int        `json:"total"`
+	Page    int        `json:"page"`
+	Limit   int        `json:"limit"`
+}
+
+func (s *Service) loadLedger() []TxRecord {
+	var records []TxRecord
+	if data, err := os.ReadFile(filepath.Join(s.dataDir, "tx-ledger.json")); err == nil {
+		json.Unmarshal(data, &records)
+	}
+	return records
+}
+
+func (s *Service) saveLedger(records []TxRecord) {
+	if len(records) > maxLedgerRecords {
+		records = records[:maxLedgerRecords]
+	}
+	data, _ := json.MarshalIndent(records, "", "  ")
+	os.WriteFile(filepath.Join(s.dataDir, "tx-ledger.json"), data, 0600)
+}
+
+// updateLedger runs fn on the ledger and saves it if fn reports a change.
+func (s *Service) updateLedger(fn func([]TxRecord) ([]TxRecord, bool)) {
+	s.ledgerMutex.Lock()
+	defer s.ledgerMutex.Unlock()
+	if records, changed := fn(s.loadLedger()); changed {
+		s.saveLedger(records)
+	}
+}
+
+// msgTypeName turns /cosmos.staking.v1beta1.MsgDelegate into MsgDelegate.
+func msgTypeName(typeURL string) string {
+	return typeURL[strings.LastIndex(typeURL, ".")+1:]
+}
+
+// txType names a tx by its distinct message types, e.g.
+// "MsgWithdrawDelegatorReward+MsgDelegate".
+func txType(typeURLs []string) string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, u := range typeURLs {
+		if name := msgTypeName(u); !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "+")
+}
+
+func applyResult(record *TxRecord, result *tx.Result) {
+	record.Height = result.Height
+	record.Code = result.Code
+	record.Codespace = result.Codespace
+	record.RawLog = result.RawLog
+	if result.Fee != "" {
+		record.Fee = result.Fee
+	}
+	switch {
+	case result.Code != 0:
+		record.Status = TxFailed
+	case result.Height > 0:
+		record.Status = TxConfirmed
+	default:
+		record.Status = TxPending
+	}
+	record.UpdatedAt = time.Now().Unix()
+}
+
+// recordTx adds a tx the tool just broadcast to the ledger.
+func (s *Service) recordTx(wallet string, msgs []sdk.Msg, memo string, result *tx.Result) {
+	typeURLs := make([]string, len(msgs))
+	for i, msg := range msgs {
+		typeURLs[i] = sdk.MsgTypeURL(msg)
+	}
+	record := TxRecord{
+		Hash:        result.TxHash,
+		Type:        txType(typeURLs),
+		Messages:    typeURLs,
+		Wallet:      wallet,
+		Memo:        memo,
+		Source:      "tool",
+		SubmittedAt: time.Now().Unix(),
+	}
+	applyResult(&record, result)
+
+	s.updateLedger(func(records []TxRecord) ([]TxRecord, bool) {
+		return append([]TxRecord{record}, records...), true
+	})
+}
+
+// updateTxRecord stores the outcome of a tx once it is known.
+func (s *Service) updateTxRecord(result *tx.Result) {
+	s.updateLedger(func(records []TxRecord) ([]TxRecord, bool) {
+		for i := range records {
+			if records[i].Hash == result.TxHash {
+				applyResult(&records[i], result)
+				return records, true
+			}
+		}
+		return records, false
+	})
+}
+
+// runLedgerPoller confirms the pending transactions of the ledger, marking
+// them failed or timed out when they do not make it into a block.
+func (s *Service) runLedgerPoller() {
+	for range time.Tick(ledgerPollInterval) {
+		s.pollPendingTxs()
+	}
+}
+
+func (s *Service) pollPendingTxs() {
+	s.ledgerMutex.Lock()
+	var pending []TxRecord
+	for _, r := range s.loadLedger() {
+		if r.Status == TxPending {
+			pending = append(pending, r)
+		}
+	}
+	s.ledgerMutex.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ledgerPollInterval)
+	defer cancel()
+	client := s.txClient()
+
+	for _, r := range pending {
+		result, err := client.GetTx(ctx, r.Hash)
+		if err == nil {
+			s.updateTxRecord(result)
+			if result.Code != 0 {
+				s.addLog(fmt.Sprintf("Transaction %s failed in block %d: %s", r.Hash, result.Height, result.RawLog))
+			}
+			continue
+		}
+		// Any lookup error counts, as a node without a tx index never
+		// answers NotFound
+		if time.Since(time.Unix(r.SubmittedAt, 0)) > ledgerTxTimeout {
+			hash := r.Hash
+			s.updateLedger(func(records []TxRecord) ([]TxRecord, bool) {
+				for i := range records {
+					if records[i].Hash == hash && records[i].Status == TxPending {
+						records[i].Status = TxTimeout
+						records[i].UpdatedAt = time.Now().Unix()
+						return records, true
+					}
+				}
+				return records, false
+			})
+			s.addLog(fmt.Sprintf("Transaction %s was not included after %s", hash, ledgerTxTimeout))
+		}
+	}
+}
+
+// GetTxHistory pages through the ledger, newest first.
+func (s *Service) GetTxHistory(q TxQuery) TxPage {
+	if q.Limit <= 0 || q.Limit > 200 {
+		q.Limit = 50
+	}
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+
+	s.ledgerMutex.Lock()
+	records := s.loadLedger()
+	s.ledgerMutex.Unlock()
+
+	matched := make([]TxRecord, 0, len(records))
+	for _, r := range records {
+		if q.Status != "" && r.Status != q.Status {
+			continue
+		}
+		if q.Type != "" && !strings.Contains(strings.ToLower(r.Type), strings.ToLower(q.Type)) {
+			continue
+		}
+		if q.Wallet != "" && r.Wallet != q.Wallet {
+			continue
+		}
+		matched = append(matched, r)
+	}
+
+	page := TxPage{Records: []TxRecord{}, Total: len(matched), Page: q.Page, Limit: q.Limit}
+	start := (q.Page - 1) * q.Limit
+	if start < len(matched) {
+		end := start + q.Limit
+		if end > len(matched) {
+			end = len(matched)
+		}
+		page.Records = matched[start:end]
+	}
+	return page
+}
+
+// ImportTxHistory adds the transactions the active wallet sent or received
+// on chain, as found by the node's tx search, to the ledger. It returns how
+// many were new.
+func (s *Service) ImportTxHistory() (int, error) {
+	address, _, err := s.GetWalletInfo()
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	client := s.txClient()
+
+	found := make(map[string]TxRecord)
+	for _, query := range []string{
+		fmt.Sprintf("message.sender='%s'", address),
+		fmt.Sprintf("transfer.recipient='%s'", address),
+	} {
+		for page := 1; page <= importMaxPages; page++ {
+			txs, total, err := client.SearchTxs(ctx, query, page, importPageSize)
+			if err != nil {
+				return 0, err
+			}
+			for _, t := range txs {
+				record := TxRecord{
+					Hash:     t.TxHash,
+					Type:     txType(t.Messages),
+					Messages: t.Messages,
+					Wallet:   address,
+					Memo:     t.Memo,
+					Source:   "chain",
+				}
+				result := t.Result
+				applyResult(&record, &result)
+				if ts, err := time.Parse(time.RFC3339, t.Timestamp); err == nil {
+					record.SubmittedAt = ts.Unix()
+					record.UpdatedAt = ts.Unix()
+				}
+				found[record.Hash] = record
+			}
+			if len(txs) < importPageSize || page*importPageSize >= total {
+				break
+			}
+		}
+	}
+
+	imported := 0
+	s.updateLedger(func(records []TxRecord) ([]TxRecord, bool) {
+		known := make(map[string]bool, len(records))
+		for _, r := range records {
+			known[r.Hash] = true
+		}
+		for hash, record := range found {
+			if !known[hash] {
+				records = append(records, record)
+				imported++
+			}
+		}
+		sort.SliceStable(records, func(i, j int) bool {
+			return records[i].SubmittedAt > records[j].SubmittedAt
+		})
+		return records, imported > 0
+	})
+
+	s.addLog(fmt.Sprintf("Imported %d transactions of %s from the chain", imported, address))
+	return imported, nil
+}
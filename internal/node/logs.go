@@ -0,0 +1,649 @@
+package node
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// LOG STORE
+// =============================================================================
+
+const (
+	// Structured entries, rotated by the store itself
+	maxLogFileSize = 10 * 1024 * 1024
+	maxLogFiles    = 5
+
+	// Raw node output, written and rotated by the log pump
+	maxNodeLogSize  = 50 * 1024 * 1024
+	maxNodeLogFiles = 3
+)
+
+type LogEntry struct {
+	ID      int64             `json:"id"`
+	Time    time.Time         `json:"time"`
+	Level   string            `json:"level"`
+	Module  string            `json:"module,omitempty"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+	Raw     string            `json:"raw"`
+}
+
+type LogQuery struct {
+	Query  string
+	Level  string // minimum level
+	Module string
+	Since  time.Time
+	Until  time.Time
+	Cursor int64 // only entries older than this ID
+	Limit  int
+}
+
+type LogPage struct {
+	Entries    []LogEntry `json:"entries"`
+	NextCursor int64      `json:"nextCursor,omitempty"`
+}
+
+var levelRank = map[string]int{
+	"debug": 0,
+	"info":  1,
+	"warn":  2,
+	"error": 3,
+}
+
+// logStore appends entries as JSON lines to logs/entries.jsonl and rotates
+// it to entries.jsonl.1 ... entries.jsonl.N once it grows too large.
+type logStore struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	size   int64
+	nextID int64
+}
+
+func newLogStore(dir string) *logStore {
+	os.MkdirAll(dir, 0700)
+	store := &logStore{path: filepath.Join(dir, "entries.jsonl")}
+	store.nextID = store.lastID() + 1
+	return store
+}
+
+// lastID reads the ID of the newest entry on disk so IDs keep increasing
+// across restarts.
+func (l *logStore) lastID() int64 {
+	for _, path := range []string{l.path, l.path + ".1"} {
+		lines := readLastLines(path, 1)
+		if len(lines) == 0 {
+			continue
+		}
+		var entry LogEntry
+		if json.Unmarshal([]byte(lines[0]), &entry) == nil {
+			return entry.ID
+		}
+	}
+	return 0
+}
+
+func (l *logStore) append(entry *LogEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.ID = l.nextID
+	l.nextID++
+
+	if l.file == nil {
+		f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return
+		}
+		info, _ := f.Stat()
+		l.file = f
+		l.size = info.Size()
+	}
+
+	data, _ := json.Marshal(entry)
+	data = append(data, '\n')
+	n, _ := l.file.Write(data)
+	l.size += int64(n)
+
+	if l.size >= maxLogFileSize {
+		l.file.Close()
+		l.file = nil
+		shiftRotatedFiles(l.path, maxLogFiles)
+		os.Rename(l.path, l.path+".1")
+	}
+}
+
+// query walks the files from newest to oldest, each one from its end, and
+// returns the newest matching entries older than the cursor, in
+// chronological order. It stops reading as soon as the page is full, and
+// files whose first entry is not older than the cursor are skipped.
+func (l *logStore) query(q LogQuery, match func(*LogEntry) bool) LogPage {
+	l.mu.Lock()
+	paths := []string{l.path}
+	for i := 1; i <= maxLogFiles; i++ {
+		paths = append(paths, fmt.Sprintf("%s.%d", l.path, i))
+	}
+	l.mu.Unlock()
+
+	var matched []LogEntry
+	more := false
+
+	for _, path := range paths {
+		if q.Cursor > 0 && firstID(path) >= q.Cursor {
+			continue
+		}
+		readLinesBackward(path, func(line []byte) bool {
+			var entry LogEntry
+			if json.Unmarshal(line, &entry) != nil {
+				return true
+			}
+			if (q.Cursor > 0 && entry.ID >= q.Cursor) || !match(&entry) {
+				return true
+			}
+			if len(matched) == q.Limit {
+				more = true
+				return false
+			}
+			matched = append(matched, entry)
+			return true
+		})
+		if more {
+			break
+		}
+	}
+
+	sort.Slice(matched, func(a, b int) bool { return matched[a].ID < matched[b].ID })
+
+	page := LogPage{Entries: matched}
+	if page.Entries == nil {
+		page.Entries = []LogEntry{}
+	}
+	if more && len(matched) > 0 {
+		page.NextCursor = matched[0].ID
+	}
+	return page
+}
+
+// firstID returns the ID of the oldest entry in a file, or 0.
+func firstID(path string) int64 {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	line, _ := bufio.NewReader(f).ReadBytes('\n')
+	var entry LogEntry
+	if json.Unmarshal(line, &entry) != nil {
+		return 0
+	}
+	return entry.ID
+}
+
+// readLinesBackward calls fn with the lines of a file from the last to the
+// first, reading it in chunks from the end, until fn returns false.
+func readLinesBackward(path string, fn func(line []byte) bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return
+	}
+
+	const chunk = 64 * 1024
+	buf := make([]byte, chunk)
+	pos := info.Size()
+	// The start of the line cut by the previous chunk
+	var rest []byte
+	for pos > 0 {
+		n := int64(chunk)
+		if pos < n {
+			n = pos
+		}
+		pos -= n
+		if _, err := f.ReadAt(buf[:n], pos); err != nil {
+			return
+		}
+
+		data := append(buf[:n:n], rest...)
+		for {
+			i := bytes.LastIndexByte(data, '\n')
+			if i < 0 {
+				break
+			}
+			if line := data[i+1:]; len(line) > 0 && !fn(line) {
+				return
+			}
+			data = data[:i]
+		}
+		rest = append([]byte(nil), data...)
+	}
+	if len(rest) > 0 {
+		fn(rest)
+	}
+}
+
+// shiftRotatedFiles renames path.(i) to path.(i+1), dropping the oldest,
+// so path.1 is free for the file being rotated.
+func shiftRotatedFiles(path string, keep int) {
+	os.Remove(fmt.Sprintf("%s.%d", path, keep))
+	for i := keep - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+	}
+}
+
+// LogPumpCommand is the argument that makes the server binary run as the
+// node's log pump instead of serving; see RunLogPump.
+const LogPumpCommand = "log-pump"
+
+// startLogPump starts the log pump for path as a detached process and
+// returns the write end of its input, for the node's stdout and stderr.
+func startLogPump(path string) (*os.File, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	cmd := exec.Command(exe, LogPumpCommand, path)
+	cmd.Stdin = r
+	detachProcess(cmd)
+	if err := cmd.Start(); err != nil {
+		w.Close()
+		return nil, fmt.Errorf("erro ao iniciar gravação do log do node: %v", err)
+	}
+	go cmd.Wait()
+	return w, nil
+}
+
+// RunLogPump copies the node output from stdin to path and rotates the file
+// by renaming it once it grows past maxNodeLogSize. It runs as its own
+// process, so the node keeps a reader for its output while this server
+// restarts, and as the only writer it rotates between two lines without
+// losing any. It returns once the node has exited and closed the pipe.
+func RunLogPump(path string) error {
+	input := bufio.NewReader(os.Stdin)
+
+	var file *os.File
+	var size int64
+	open := func() error {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return err
+		}
+		file, size = f, info.Size()
+		return nil
+	}
+	if err := open(); err != nil {
+		// Keep reading so the node is not killed by a closed pipe
+		io.Copy(io.Discard, input)
+		return err
+	}
+	defer func() { file.Close() }()
+
+	for {
+		line, err := input.ReadBytes('\n')
+		if len(line) > 0 {
+			n, _ := file.Write(line)
+			size += int64(n)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if size >= maxNodeLogSize {
+			file.Close()
+			shiftRotatedFiles(path, maxNodeLogFiles)
+			os.Rename(path, path+".1")
+			if err := open(); err != nil {
+				io.Copy(io.Discard, input)
+				return err
+			}
+		}
+	}
+}
+
+// QueryLogs searches the persisted node log. In systemd mode the journal is
+// searched instead, merged with what this server logged itself, such as
+// setup steps.
+func (s *Service) QueryLogs(q LogQuery) LogPage {
+	if q.Limit <= 0 || q.Limit > 1000 {
+		q.Limit = 100
+	}
+
+	if s.isSystemdMode() {
+		return s.querySystemdLogs(q)
+	}
+	return s.logStore.query(q, q.matches)
+}
+
+// querySystemdLogs merges a page of the journal with the same page of our
+// own entries. Those get their time in microseconds as ID, like journal
+// entries, so the cursor covers both.
+func (s *Service) querySystemdLogs(q LogQuery) LogPage {
+	journal := s.queryJournal(q)
+	own := s.logStore.query(LogQuery{Limit: q.Limit}, func(entry *LogEntry) bool {
+		return (q.Cursor == 0 || entry.Time.UnixMicro() < q.Cursor) && q.matches(entry)
+	})
+
+	entries := journal.Entries
+	for _, entry := range own.Entries {
+		entry.ID = entry.Time.UnixMicro()
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+
+	more := journal.NextCursor != 0 || own.NextCursor != 0
+	if len(entries) > q.Limit {
+		entries = entries[len(entries)-q.Limit:]
+		more = true
+	}
+
+	page := LogPage{Entries: entries}
+	if more && len(entries) > 0 {
+		page.NextCursor = entries[0].ID
+	}
+	return page
+}
+
+func (q LogQuery) matches(entry *LogEntry) bool {
+	if !q.Until.IsZero() && entry.Time.After(q.Until) {
+		return false
+	}
+	if !q.Since.IsZero() && entry.Time.Before(q.Since) {
+		return false
+	}
+	if q.Level != "" && levelRank[entry.Level] < levelRank[q.Level] {
+		return false
+	}
+	if q.Module != "" && entry.Module != q.Module {
+		return false
+	}
+	if q.Query != "" && !strings.Contains(strings.ToLower(entry.Raw), strings.ToLower(q.Query)) {
+		return false
+	}
+	return true
+}
+
+// =============================================================================
+// LOG PARSING
+// =============================================================================
+
+var (
+	ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+	// zerolog console output used by the SDK: "3:04PM INF message key=value"
+	consolePattern = regexp.MustCompile(`^(\d{1,2}:\d{2}(?:AM|PM))\s+(TRC|DBG|INF|WRN|ERR|FTL|PNC)\s+(.*)$`)
+
+	// Legacy CometBFT output: "I[2006-01-02|15:04:05.000] message key=value"
+	legacyPattern = regexp.MustCompile(`^([DIEW])\[(\d{4}-\d{2}-\d{2}\|\d{2}:\d{2}:\d{2}\.\d{3})\]\s+(.*)$`)
+
+	keyPattern = regexp.MustCompile(`^[A-Za-z_][\w.\-]*=`)
+)
+
+var consoleLevels = map[string]string{
+	"TRC": "debug",
+	"DBG": "debug",
+	"INF": "info",
+	"WRN": "warn",
+	"ERR": "error",
+	"FTL": "error",
+	"PNC": "error",
+}
+
+var legacyLevels = map[string]string{
+	"D": "debug",
+	"I": "info",
+	"W": "warn",
+	"E": "error",
+}
+
+// parseLogLine turns one line of node output into an entry. Lines in an
+// unknown format are kept whole as an info message.
+func parseLogLine(line string, received time.Time) LogEntry {
+	line = ansiPattern.ReplaceAllString(line, "")
+	entry := LogEntry{
+		Time:    received,
+		Level:   "info",
+		Message: line,
+		Raw:     line,
+	}
+
+	if strings.HasPrefix(line, "{") {
+		if parseJSONLogLine(line, &entry) {
+			return entry
+		}
+	}
+
+	var rest string
+	if m := consolePattern.FindStringSubmatch(line); m != nil {
+		entry.Level = consoleLevels[m[2]]
+		rest = m[3]
+	} else if m := legacyPattern.FindStringSubmatch(line); m != nil {
+		entry.Level = legacyLevels[m[1]]
+		if t, err := time.ParseInLocation("2006-01-02|15:04:05.000", m[2], time.Local); err == nil {
+			entry.Time = t
+		}
+		rest = m[3]
+	} else {
+		if strings.HasPrefix(line, "panic:") {
+			entry.Level = "error"
+		}
+		return entry
+	}
+
+	entry.Message, entry.Fields = splitFields(rest)
+	if module, ok := entry.Fields["module"]; ok {
+		entry.Module = module
+		delete(entry.Fields, "module")
+	}
+	return entry
+}
+
+func parseJSONLogLine(line string, entry *LogEntry) bool {
+	var raw map[string]interface{}
+	if json.Unmarshal([]byte(line), &raw) != nil {
+		return false
+	}
+
+	entry.Fields = make(map[string]string)
+	for key, value := range raw {
+		str := fmt.Sprint(value)
+		switch key {
+		case "level":
+			entry.Level = normalizeLevel(str)
+		case "module":
+			entry.Module = str
+		case "message", "msg", "_msg":
+			entry.Message = str
+		case "time":
+			if t, err := time.Parse(time.RFC3339Nano, str); err == nil {
+				entry.Time = t
+			}
+		default:
+			entry.Fields[key] = str
+		}
+	}
+	return true
+}
+
+func normalizeLevel(level string) string {
+	switch strings.ToLower(level) {
+	case "trace", "debug":
+		return "debug"
+	case "warn", "warning":
+		return "warn"
+	case "error", "fatal", "panic":
+		return "error"
+	default:
+		return "info"
+	}
+}
+
+// splitFields separates the free text message from the trailing key=value
+// pairs. Values may be double quoted and contain spaces.
+func splitFields(text string) (string, map[string]string) {
+	tokens := tokenize(text)
+
+	first := len(tokens)
+	for i, tok := range tokens {
+		if keyPattern.MatchString(tok) {
+			first = i
+			break
+		}
+	}
+
+	message := strings.Join(tokens[:first], " ")
+	if first == len(tokens) {
+		return message, nil
+	}
+
+	fields := make(map[string]string)
+	for _, tok := range tokens[first:] {
+		key, value, ok := strings.Cut(tok, "=")
+		if !ok {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		fields[key] = value
+	}
+	return message, fields
+}
+
+// tokenize splits on spaces, keeping double quoted sections together.
+func tokenize(text string) []string {
+	var tokens []string
+	var current strings.Builder
+	inQuotes := false
+	escaped := false
+
+	for _, r := range text {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && inQuotes:
+			escaped = true
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == ' ' && !inQuotes:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+			continue
+		}
+		current.WriteRune(r)
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+// queryJournal runs the query against journalctl. Entries use the journal
+// timestamp in microseconds as their ID, so cursors work the same way.
+func (s *Service) queryJournal(q LogQuery) LogPage {
+	cfg := s.loadRuntimeConfig()
+	args := []string{"-u", cfg.SystemdUnit, "--no-pager", "-o", "json", "-n", "5000"}
+	if cfg.SystemdScope == "user" {
+		args = append([]string{"--user"}, args...)
+	}
+	if !q.Since.IsZero() {
+		args = append(args, "--since", "@"+strconv.FormatInt(q.Since.Unix(), 10))
+	}
+	until := q.Until
+	if q.Cursor > 0 {
+		if cursorTime := time.UnixMicro(q.Cursor); until.IsZero() || cursorTime.Before(until) {
+			until = cursorTime
+		}
+	}
+	if !until.IsZero() {
+		args = append(args, "--until", "@"+strconv.FormatInt(until.Unix()+1, 10))
+	}
+
+	output, err := s.runner.Run("journalctl", args...)
+	if err != nil {
+		return LogPage{Entries: []LogEntry{}}
+	}
+
+	var entries []LogEntry
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		id, msg, ok := parseJournalLine(scanner.Bytes())
+		if !ok || (q.Cursor > 0 && id >= q.Cursor) {
+			continue
+		}
+		entry := parseLogLine(msg, time.UnixMicro(id))
+		entry.ID = id
+		if q.matches(&entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	page := LogPage{Entries: entries}
+	if len(entries) > q.Limit {
+		page.Entries = entries[len(entries)-q.Limit:]
+		page.NextCursor = page.Entries[0].ID
+	}
+	if page.Entries == nil {
+		page.Entries = []LogEntry{}
+	}
+	return page
+}
+
+// parseJournalLine reads the timestamp and message of a journalctl -o json
+// line. journald stores non UTF-8 messages as byte arrays.
+func parseJournalLine(line []byte) (int64, string, bool) {
+	var entry struct {
+		Timestamp string          `json:"__REALTIME_TIMESTAMP"`
+		Message   json.RawMessage `json:"MESSAGE"`
+	}
+	if json.Unmarshal(line, &entry) != nil {
+		return 0, "", false
+	}
+
+	var msg string
+	if json.Unmarshal(entry.Message, &msg) != nil {
+		var raw []int
+		json.Unmarshal(entry.Message, &raw)
+		b := make([]byte, len(raw))
+		for i, c := range raw {
+			b[i] = byte(c)
+		}
+		msg = string(b)
+	}
+
+	usec, _ := strconv.ParseInt(entry.Timestamp, 10, 64)
+	return usec, msg, true
+}
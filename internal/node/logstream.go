@@ -0,0 +1,190 @@
+package node
+
+import (
+	"bufio"
+	"sort"
+	"time"
+)
+
+// =============================================================================
+// LOG STREAMING
+// =============================================================================
+
+const (
+	logSubscriberBuffer  = 256
+	maxLogReplay         = 5000
+	journalCheckInterval = 5 * time.Second
+)
+
+// LogSubscription delivers new log entries matching its filter. When the
+// consumer falls behind and the buffer fills up, the subscription is cut
+// off instead of blocking the log writers: Entries is closed and Lagged
+// returns true, so the client can reconnect and replay from its last ID.
+type LogSubscription struct {
+	Entries <-chan LogEntry
+
+	ch     chan LogEntry
+	filter LogQuery
+	lagged bool
+}
+
+func (sub *LogSubscription) Lagged() bool {
+	return sub.lagged
+}
+
+// SubscribeLogs registers a subscriber. When after is set, the entries
+// newer than that ID are returned for replay; live entries already covered
+// by the replay should be skipped by ID.
+func (s *Service) SubscribeLogs(filter LogQuery, after int64) (*LogSubscription, []LogEntry, func()) {
+	ch := make(chan LogEntry, logSubscriberBuffer)
+	sub := &LogSubscription{Entries: ch, ch: ch, filter: filter}
+
+	// Subscribe before replaying so nothing falls between the two
+	s.logSubsMutex.Lock()
+	s.logSubs[sub] = struct{}{}
+	s.logSubsMutex.Unlock()
+
+	var replay []LogEntry
+	if after > 0 {
+		replay = s.replayLogs(filter, after)
+	}
+
+	unsubscribe := func() {
+		s.logSubsMutex.Lock()
+		defer s.logSubsMutex.Unlock()
+		if _, ok := s.logSubs[sub]; ok {
+			delete(s.logSubs, sub)
+			close(sub.ch)
+		}
+	}
+	return sub, replay, unsubscribe
+}
+
+func (s *Service) broadcastLog(entry LogEntry) {
+	s.logSubsMutex.Lock()
+	defer s.logSubsMutex.Unlock()
+
+	for sub := range s.logSubs {
+		if !sub.filter.matches(&entry) {
+			continue
+		}
+		select {
+		case sub.ch <- entry:
+		default:
+			sub.lagged = true
+			delete(s.logSubs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// replayLogs returns up to maxLogReplay entries newer than after, oldest
+// first.
+func (s *Service) replayLogs(filter LogQuery, after int64) []LogEntry {
+	if s.isSystemdMode() {
+		// Journal IDs are timestamps in microseconds, and so are the IDs
+		// the setup entries are streamed with
+		filter.Since = time.UnixMicro(after)
+		filter.Limit = maxLogReplay
+		page := s.queryJournal(filter)
+		entries := page.Entries[:0]
+		for _, e := range page.Entries {
+			if e.ID > after {
+				entries = append(entries, e)
+			}
+		}
+
+		own := s.logStore.query(filter, func(e *LogEntry) bool {
+			return e.Module == "setup" && e.Time.UnixMicro() > after && filter.matches(e)
+		})
+		for _, e := range own.Entries {
+			e.ID = e.Time.UnixMicro()
+			entries = append(entries, e)
+		}
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+		if len(entries) > maxLogReplay {
+			entries = entries[len(entries)-maxLogReplay:]
+		}
+		return entries
+	}
+
+	var entries []LogEntry
+	for cursor := int64(0); len(entries) < maxLogReplay; {
+		q := filter
+		q.Cursor = cursor
+		q.Limit = 1000
+		page := s.logStore.query(q, func(e *LogEntry) bool {
+			return e.ID > after && filter.matches(e)
+		})
+		entries = append(page.Entries, entries...)
+		if page.NextCursor == 0 || page.NextCursor <= after+1 {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if len(entries) > maxLogReplay {
+		entries = entries[len(entries)-maxLogReplay:]
+	}
+	return entries
+}
+
+// followJournal feeds the unit's journal to log subscribers while running in
+// systemd mode. The journal already persists it, so entries are not stored.
+// journalctl is stopped once the mode or the unit changes.
+func (s *Service) followJournal() {
+	var lastError string
+	for {
+		if !s.isSystemdMode() {
+			time.Sleep(journalCheckInterval)
+			continue
+		}
+
+		cfg := s.loadRuntimeConfig()
+		args := []string{"-u", cfg.SystemdUnit, "-f", "-n", "0", "--no-pager", "-o", "json"}
+		if cfg.SystemdScope == "user" {
+			args = append([]string{"--user"}, args...)
+		}
+
+		stream, err := s.runner.Stream("journalctl", args...)
+		if err != nil {
+			time.Sleep(journalCheckInterval)
+			continue
+		}
+
+		stop := make(chan struct{})
+		go func() {
+			for {
+				select {
+				case <-stop:
+					return
+				case <-time.After(journalCheckInterval):
+				}
+				current := s.loadRuntimeConfig()
+				if current.Mode != cfg.Mode || current.SystemdUnit != cfg.SystemdUnit || current.SystemdScope != cfg.SystemdScope {
+					stream.Close()
+					return
+				}
+			}
+		}()
+
+		scanner := bufio.NewScanner(stream)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			id, msg, ok := parseJournalLine(scanner.Bytes())
+			if !ok {
+				// journalctl's own errors, which repeat on every restart
+				if line := scanner.Text(); line != lastError {
+					lastError = line
+					s.addLog("journalctl: " + line)
+				}
+				continue
+			}
+			entry := parseLogLine(msg, time.UnixMicro(id))
+			entry.ID = id
+			s.broadcastLog(entry)
+		}
+		close(stop)
+		stream.Close()
+		time.Sleep(time.Second)
+	}
+}
@@ -0,0 +1,33 @@
+//go:build !windows
+
+package node
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+// detachProcess starts the node in its own session. It survives a restart of
+// the setup server, and Cosmovisor and the daemon it spawns share a process
+// group that receives signals together.
+func detachProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+func signalProcessGroup(pid int, signal string) error {
+	sig := syscall.SIGTERM
+	if signal == "SIGINT" {
+		sig = syscall.SIGINT
+	}
+	return syscall.Kill(-pid, sig)
+}
+
+func killProcessGroup(pid int) error {
+	return syscall.Kill(-pid, syscall.SIGKILL)
+}
@@ -0,0 +1,36 @@
+//go:build windows
+
+package node
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+func detachProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+func processAlive(pid int) bool {
+	// FindProcess opens a handle on Windows and fails for dead processes
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
+
+// Windows has no SIGTERM, the process is terminated right away.
+func signalProcessGroup(pid int, signal string) error {
+	return killProcessGroup(pid)
+}
+
+func killProcessGroup(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
@@ -0,0 +1,300 @@
+package node
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// DETACHED PROCESS
+// =============================================================================
+
+// nodeProcess is the node we are supervising, either spawned by this server
+// or adopted from a previous run through the PID file.
+type nodeProcess struct {
+	pid     int
+	done    chan struct{} // closed when the process is gone
+	drained chan struct{} // closed when the log file has been read to the end
+}
+
+func newNodeProcess(pid int) *nodeProcess {
+	return &nodeProcess{
+		pid:     pid,
+		done:    make(chan struct{}),
+		drained: make(chan struct{}),
+	}
+}
+
+func (s *Service) getPIDFilePath() string {
+	return filepath.Join(s.dataDir, "node.pid")
+}
+
+func (s *Service) getNodeLogPath() string {
+	return filepath.Join(s.dataDir, "logs", "node.log")
+}
+
+func (s *Service) writePIDFile(pid int) {
+	os.WriteFile(s.getPIDFilePath(), []byte(strconv.Itoa(pid)), 0600)
+}
+
+func (s *Service) readPIDFile() int {
+	data, err := os.ReadFile(s.getPIDFilePath())
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
+
+// reattachRetryInterval is how often reattachNode asks the RPC again while
+// the node is still starting.
+const reattachRetryInterval = 5 * time.Second
+
+// reattachNode adopts a node left running by a previous server instance.
+// The PID alone is not trusted since it may have been reused, the process
+// must also answer on the RPC port with our node ID. A node still starting
+// has no RPC yet, so it is asked again for as long as the process lives.
+func (s *Service) reattachNode() {
+	pid := s.readPIDFile()
+	if pid <= 0 {
+		return
+	}
+
+	for waiting := false; ; waiting = true {
+		if !processAlive(pid) {
+			if s.readPIDFile() == pid {
+				os.Remove(s.getPIDFilePath())
+			}
+			s.addLog(fmt.Sprintf("Previous node process (pid %d) is gone", pid))
+			return
+		}
+
+		err := s.verifyNodeRPC()
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errRPCUnreachable) {
+			s.addLog(fmt.Sprintf("Process %d from node.pid not adopted: %v", pid, err))
+			os.Remove(s.getPIDFilePath())
+			return
+		}
+		if !waiting {
+			s.addLog(fmt.Sprintf("Process %d from node.pid not adopted yet: %v, retrying", pid, err))
+		}
+		time.Sleep(reattachRetryInterval)
+	}
+
+	s.nodeMutex.Lock()
+	defer s.nodeMutex.Unlock()
+	if s.nodeProc != nil || s.readPIDFile() != pid {
+		return
+	}
+
+	proc := newNodeProcess(pid)
+	s.nodeProc = proc
+	s.nodeState = NodeRunning
+	s.wantRunning = true
+	s.startedAt = time.Now()
+	if info, err := os.Stat(s.getPIDFilePath()); err == nil {
+		s.startedAt = info.ModTime()
+	}
+
+	logPath := s.getNodeLogPath()
+	var offset int64
+	if info, err := os.Stat(logPath); err == nil {
+		offset = info.Size()
+		// Only into memory, these lines were stored before the restart
+		s.logsMutex.Lock()
+		for _, line := range readLastLines(logPath, 100) {
+			s.logs = append(s.logs, "[--:--:--] "+line)
+		}
+		s.logsMutex.Unlock()
+	}
+
+	go s.followLog(logPath, offset, proc)
+	go func() {
+		// Not our child, so there is no Wait; poll until it disappears
+		for processAlive(pid) {
+			time.Sleep(2 * time.Second)
+		}
+		close(proc.done)
+		<-proc.drained
+		s.handleExit(proc, -1, "exit status unknown (adopted process)")
+	}()
+
+	s.addLog(fmt.Sprintf("Reattached to running node (pid %d)", pid))
+}
+
+var errRPCUnreachable = errors.New("RPC não respondeu")
+
+// verifyNodeRPC checks that the node answering on the RPC port is the one
+// whose node key lives in our node home.
+func (s *Service) verifyNodeRPC() error {
+	expectedID, err := s.getNodeID()
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("http://localhost:26657/status")
+	if err != nil {
+		return errRPCUnreachable
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Result struct {
+			NodeInfo struct {
+				ID      string `json:"id"`
+				Network string `json:"network"`
+			} `json:"node_info"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return errRPCUnreachable
+	}
+
+	if result.Result.NodeInfo.ID != expectedID {
+		return fmt.Errorf("RPC pertence a outro node (%s)", result.Result.NodeInfo.ID)
+	}
+	return nil
+}
+
+// getNodeID derives the CometBFT node ID (hex of the first 20 bytes of the
+// SHA-256 of the ed25519 public key) from config/node_key.json.
+func (s *Service) getNodeID() (string, error) {
+	data, err := os.ReadFile(filepath.Join(s.getNodeHome(), "config", "node_key.json"))
+	if err != nil {
+		return "", errors.New("node_key.json não encontrado")
+	}
+
+	var key struct {
+		PrivKey struct {
+			Value string `json:"value"`
+		} `json:"priv_key"`
+	}
+	if err := json.Unmarshal(data, &key); err != nil {
+		return "", err
+	}
+
+	priv, err := base64.StdEncoding.DecodeString(key.PrivKey.Value)
+	if err != nil || len(priv) != 64 {
+		return "", errors.New("node_key.json inválido")
+	}
+
+	hash := sha256.Sum256(priv[32:])
+	return hex.EncodeToString(hash[:20]), nil
+}
+
+// followLog feeds lines appended to the node log file into the in-memory
+// log until proc is gone. The log pump rotates the file by renaming it, so
+// once the path names a new file the one open is read to its end first.
+func (s *Service) followLog(path string, offset int64, proc *nodeProcess) {
+	defer close(proc.drained)
+
+	var file *os.File
+	var reader *bufio.Reader
+	var partial string
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	drain := func() {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				partial += line
+				return
+			}
+			s.addNodeLog(strings.TrimRight(partial+line, "\r\n"))
+			partial = ""
+		}
+	}
+	readNew := func() {
+		if file == nil {
+			f, err := os.Open(path)
+			if err != nil {
+				return
+			}
+			f.Seek(offset, io.SeekStart)
+			file, reader = f, bufio.NewReader(f)
+		}
+
+		for {
+			drain()
+			current, err := os.Stat(path)
+			if err != nil {
+				return
+			}
+			if opened, err := file.Stat(); err != nil || os.SameFile(current, opened) {
+				return
+			}
+			// Renamed away, so nothing is written to it after this
+			drain()
+			f, err := os.Open(path)
+			if err != nil {
+				return
+			}
+			file.Close()
+			file, reader = f, bufio.NewReader(f)
+		}
+	}
+
+	for {
+		select {
+		case <-proc.done:
+			readNew()
+			if partial != "" {
+				s.addNodeLog(partial)
+			}
+			return
+		case <-time.After(500 * time.Millisecond):
+			readNew()
+		}
+	}
+}
+
+// readLastLines returns up to n complete lines from the end of the file.
+func readLastLines(path string, n int) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	const chunk = 64 * 1024
+	seeked := false
+	if info, err := f.Stat(); err == nil && info.Size() > chunk {
+		f.Seek(-chunk, io.SeekEnd)
+		seeked = true
+	}
+	data, _ := io.ReadAll(f)
+
+	content := strings.TrimRight(string(data), "\n")
+	if content == "" {
+		return nil
+	}
+	lines := strings.Split(content, "\n")
+	if seeked {
+		// The first line was cut by the seek
+		lines = lines[1:]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
@@ -0,0 +1,164 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	sdkmath "cosmossdk.io/math"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	distrtypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/tickfy/tickfy-validator-setup/internal/tx"
+)
+
+// =============================================================================
+// RESTAKE
+// =============================================================================
+
+// RestakeConfig is read from restake-config.json in the data dir.
+type RestakeConfig struct {
+	// FeeReserve is the amount, in base units of the bond denom, kept out
+	// of the delegation so the wallet can still pay for transactions.
+	FeeReserve string `json:"feeReserve"`
+}
+
+type RestakeResult struct {
+	Denom      string     `json:"denom"`
+	Rewards    string     `json:"rewards"`
+	Commission string     `json:"commission"`
+	FeeReserve string     `json:"feeReserve"`
+	Delegated  string     `json:"delegated"`
+	Tx         *tx.Result `json:"tx"`
+}
+
+// decCoin is a DecCoin as the REST API returns it.
+type decCoin struct {
+	Denom  string `json:"denom"`
+	Amount string `json:"amount"`
+}
+
+// amountOf returns the whole part of denom in coins; the fraction stays in
+// the distribution module.
+func amountOf(coins []decCoin, denom string) sdkmath.Int {
+	for _, c := range coins {
+		if c.Denom != denom {
+			continue
+		}
+		if dec, err := sdkmath.LegacyNewDecFromStr(c.Amount); err == nil {
+			return dec.TruncateInt()
+		}
+	}
+	return sdkmath.ZeroInt()
+}
+
+func (s *Service) loadRestakeConfig() RestakeConfig {
+	cfg := RestakeConfig{FeeReserve: "100000"}
+	if data, err := os.ReadFile(filepath.Join(s.dataDir, "restake-config.json")); err == nil {
+		json.Unmarshal(data, &cfg)
+	}
+	return cfg
+}
+
+// Restake withdraws the wallet's rewards from our validator and the
+// validator commission, and delegates them back minus the fee reserve, all
+// in one transaction. An empty feeReserve uses the configured one.
+func (s *Service) Restake(password, walletID, feeReserve string) (*RestakeResult, error) {
+	key, _, err := s.unlockKey(walletID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	if feeReserve == "" {
+		feeReserve = s.loadRestakeConfig().FeeReserve
+	}
+	reserve, ok := sdkmath.NewIntFromString(feeReserve)
+	if !ok || reserve.IsNegative() {
+		return nil, errors.New("reserva para taxas inválida")
+	}
+
+	denom, err := s.getBondDenom()
+	if err != nil {
+		return nil, err
+	}
+
+	delegator := key.Address()
+	validator := key.ValidatorAddress()
+
+	rewards, commission, err := s.queryPendingRewards(delegator, validator, denom)
+	if err != nil {
+		return nil, err
+	}
+	delegate := rewards.Add(commission).Sub(reserve)
+	if !delegate.IsPositive() {
+		return nil, fmt.Errorf("recompensas (%s%s) não cobrem a reserva para taxas (%s%s)", rewards.Add(commission), denom, reserve, denom)
+	}
+
+	msgs := restakeMsgs(delegator, validator, denom, rewards, commission, delegate)
+	result, err := s.sendTx(key, msgs, "restake")
+	if result == nil {
+		return nil, fmt.Errorf("erro no restake: %v", err)
+	}
+	restake := &RestakeResult{
+		Denom:      denom,
+		Rewards:    rewards.String(),
+		Commission: commission.String(),
+		FeeReserve: reserve.String(),
+		Delegated:  delegate.String(),
+		Tx:         result,
+	}
+	// A rejected transaction still has a hash, as for the other sendTx callers
+	if err != nil {
+		return restake, fmt.Errorf("erro no restake: %v", err)
+	}
+
+	s.addLog(fmt.Sprintf("Restaked %s%s to %s (tx %s)", delegate, denom, validator, result.TxHash))
+	return restake, nil
+}
+
+// queryPendingRewards returns the rewards of delegator at our validator and
+// the commission of the validator, in whole units of denom.
+func (s *Service) queryPendingRewards(delegator, validator, denom string) (sdkmath.Int, sdkmath.Int, error) {
+	zero := sdkmath.ZeroInt()
+
+	var valResp struct {
+		Validator struct {
+			OperatorAddress string `json:"operator_address"`
+		} `json:"validator"`
+	}
+	if err := s.queryChain("/cosmos/staking/v1beta1/validators/"+validator, &valResp); err != nil {
+		return zero, zero, fmt.Errorf("carteira não opera um validador (%s): %v", validator, err)
+	}
+
+	var rewardsResp struct {
+		Rewards []decCoin `json:"rewards"`
+	}
+	if err := s.queryChain(fmt.Sprintf("/cosmos/distribution/v1beta1/delegators/%s/rewards/%s", delegator, validator), &rewardsResp); err != nil {
+		return zero, zero, fmt.Errorf("erro ao consultar recompensas: %v", err)
+	}
+
+	var commissionResp struct {
+		Commission struct {
+			Commission []decCoin `json:"commission"`
+		} `json:"commission"`
+	}
+	if err := s.queryChain("/cosmos/distribution/v1beta1/validators/"+validator+"/commission", &commissionResp); err != nil {
+		return zero, zero, fmt.Errorf("erro ao consultar comissão: %v", err)
+	}
+
+	return amountOf(rewardsResp.Rewards, denom), amountOf(commissionResp.Commission.Commission, denom), nil
+}
+
+// restakeMsgs withdraws whatever there is and delegates amount.
+func restakeMsgs(delegator, validator, denom string, rewards, commission, amount sdkmath.Int) []sdk.Msg {
+	var msgs []sdk.Msg
+	if rewards.IsPositive() {
+		msgs = append(msgs, distrtypes.NewMsgWithdrawDelegatorReward(delegator, validator))
+	}
+	if commission.IsPositive() {
+		msgs = append(msgs, distrtypes.NewMsgWithdrawValidatorCommission(validator))
+	}
+	return append(msgs, stakingtypes.NewMsgDelegate(delegator, validator, sdk.NewCoin(denom, amount)))
+}
@@ -1,38 +1,82 @@
 package node
 
 import (
-	"bufio"
-	"crypto/aes"
-	"crypto/cipher"
+	"context"
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
 	"time"
 
+	sdkmath "cosmossdk.io/math"
 	"github.com/cosmos/cosmos-sdk/crypto/hd"
 	"github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
+	cryptotypes "github.com/cosmos/cosmos-sdk/crypto/types"
 	sdk "github.com/cosmos/cosmos-sdk/types"
+	distrtypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
 	"github.com/cosmos/go-bip39"
+	"github.com/tickfy/tickfy-validator-setup/internal/tx"
 )
 
 type Service struct {
 	dataDir   string
-	nodeCmd   *exec.Cmd
+	nodeProc  *nodeProcess
 	nodeMutex sync.Mutex
 	logs      []string
 	logsMutex sync.RWMutex
 	maxLogs   int
+	jobs      map[string]*Job
+	jobsMutex sync.Mutex
+	runner    CommandRunner
+	logStore  *logStore
+
+	logSubs      map[*LogSubscription]struct{}
+	logSubsMutex sync.Mutex
+	// Held while an entry gets its ID and is broadcast, so subscribers
+	// receive entries in ID order
+	publishMutex sync.Mutex
+
+	// Supervisor state, guarded by nodeMutex
+	nodeState     string
+	wantRunning   bool
+	startedAt     time.Time
+	restartTimes  []time.Time
+	restartTimer  *time.Timer
+	nextRestartAt time.Time
+	stopForced    bool
+	lastExit      *ExitStatus
+	crashMutex    sync.Mutex
+
+	compoundMutex   sync.Mutex
+	lastCompoundAt  time.Time
+	compoundRunning bool
+
+	pendingSends map[string]*pendingSend
+	sendsMutex   sync.Mutex
+
+	addressBookMutex sync.Mutex
+
+	keyringMutex sync.Mutex
+
+	ledgerMutex sync.Mutex
+
+	govMutex          sync.Mutex
+	pendingVotesCount int
+	pendingVotesAt    time.Time
+	pendingVotesBusy  bool
+
+	versionsMutex    sync.Mutex
+	nodeVersionKey   string
+	nodeVersionValue string
 }
 
 type CosmovisorConfig struct {
@@ -42,12 +86,14 @@ type CosmovisorConfig struct {
 }
 
 type WalletData struct {
-	ID                string `json:"id"`
-	Address           string `json:"address"`
-	Name              string `json:"name"`
-	EncryptedMnemonic string `json:"encryptedMnemonic"`
-	Salt              string `json:"salt"`
-	CreatedAt         int64  `json:"createdAt"`
+	ID                string     `json:"id"`
+	Address           string     `json:"address"`
+	Name              string     `json:"name"`
+	EncryptedMnemonic string     `json:"encryptedMnemonic"`
+	Salt              string     `json:"salt"`
+	Version           int        `json:"version,omitempty"` // absent for the legacy sha256 key
+	KDF               *KDFParams `json:"kdf,omitempty"`
+	CreatedAt         int64      `json:"createdAt"`
 }
 
 type WalletsStore struct {
@@ -62,18 +108,45 @@ type NodeConfig struct {
 }
 
 type ValidatorInfo struct {
-	Moniker    string `json:"moniker"`
-	Commission string `json:"commission"`
-	Stake      string `json:"stake"`
-	CreatedAt  int64  `json:"createdAt"`
+	Moniker         string `json:"moniker"`
+	Identity        string `json:"identity,omitempty"`
+	Website         string `json:"website,omitempty"`
+	SecurityContact string `json:"securityContact,omitempty"`
+	Details         string `json:"details,omitempty"`
+	Commission      string `json:"commission"`
+	Stake           string `json:"stake"`
+	CreatedAt       int64  `json:"createdAt"`
+	UpdatedAt       int64  `json:"updatedAt,omitempty"`
 }
 
 func NewService(dataDir string) *Service {
+	s := newService(dataDir, execRunner{})
+	s.migrateStartupKeyring()
+	if !s.isSystemdMode() {
+		go s.reattachNode()
+	}
+	go s.followJournal()
+	go s.runAutoCompoundScheduler()
+	go s.runLedgerPoller()
+	go s.runUpgradeWatcher()
+	return s
+}
+
+// newService builds the service without starting its background loops.
+// systemctl and journalctl calls go through runner.
+func newService(dataDir string, runner CommandRunner) *Service {
 	os.MkdirAll(dataDir, 0700)
 	return &Service{
-		dataDir: dataDir,
-		logs:    make([]string, 0),
-		maxLogs: 1000,
+		dataDir:   dataDir,
+		logs:      make([]string, 0),
+		maxLogs:   1000,
+		logStore:  newLogStore(filepath.Join(dataDir, "logs")),
+		logSubs:   make(map[*LogSubscription]struct{}),
+		jobs:      make(map[string]*Job),
+		runner:    runner,
+		nodeState: NodeStopped,
+
+		pendingSends: make(map[string]*pendingSend),
 	}
 }
 
@@ -88,16 +161,30 @@ type AppStatus struct {
 	IsNodeRunning         bool   `json:"isNodeRunning"`
 	IsValidator           bool   `json:"isValidator"`
 	IsCosmovisorInstalled bool   `json:"isCosmovisorInstalled"`
+	NodeVersion           string `json:"nodeVersion,omitempty"`
 	WalletAddress         string `json:"walletAddress,omitempty"`
 	Moniker               string `json:"moniker,omitempty"`
 	CurrentBlock          int64  `json:"currentBlock"`
 	Peers                 int    `json:"peers"`
+	PendingVotes          int    `json:"pendingVotes"`
+	TestKeyringPending    bool   `json:"testKeyringPending"`
+
+	NodeState     string       `json:"nodeState"`
+	Restarts      int          `json:"restarts"`
+	NextRestartAt int64        `json:"nextRestartAt,omitempty"`
+	LastCrash     *CrashRecord `json:"lastCrash,omitempty"`
+	LastExit      *ExitStatus  `json:"lastExit,omitempty"`
 }
 
 func (s *Service) isNodeRunning() bool {
+	if s.isSystemdMode() {
+		st := s.systemdState()
+		return st != NodeStopped && st != NodeFailed
+	}
+
 	s.nodeMutex.Lock()
 	defer s.nodeMutex.Unlock()
-	return s.nodeCmd != nil && s.nodeCmd.Process != nil
+	return s.nodeProc != nil
 }
 
 func (s *Service) GetStatus() *AppStatus {
@@ -124,6 +211,7 @@ func (s *Service) GetStatus() *AppStatus {
 	binaryPath := s.getBinaryPath()
 	if _, err := os.Stat(binaryPath); err == nil {
 		status.IsNodeInstalled = true
+		status.NodeVersion = s.nodeVersion()
 	}
 
 	// Check node initialized (via wizard - node-config.json must exist)
@@ -138,6 +226,8 @@ func (s *Service) GetStatus() *AppStatus {
 		status.IsValidator = true
 	}
 
+	status.TestKeyringPending = s.TestKeyringPending()
+
 	// Check Cosmovisor
 	cosmovisorPath := s.getCosmovisorPath()
 	if _, err := os.Stat(cosmovisorPath); err == nil {
@@ -146,12 +236,16 @@ func (s *Service) GetStatus() *AppStatus {
 
 	// Check if running and get block info
 	status.IsNodeRunning = s.isNodeRunning()
+	s.fillSupervisorStatus(status)
 
 	if status.IsNodeRunning {
 		if block, peers, err := s.getNodeInfo(); err == nil {
 			status.CurrentBlock = block
 			status.Peers = peers
 		}
+		if status.HasWallet {
+			status.PendingVotes = s.pendingVotes()
+		}
 	}
 
 	// Load moniker
@@ -210,6 +304,11 @@ func (s *Service) getNodeInfo() (int64, int, error) {
 // WALLET
 // =============================================================================
 
+var (
+	ErrNoWallet       = errors.New("nenhuma carteira encontrada")
+	ErrWalletNotFound = errors.New("carteira não encontrada")
+)
+
 func (s *Service) loadWalletsStore() (*WalletsStore, error) {
 	walletPath := filepath.Join(s.dataDir, "wallets.json")
 	data, err := os.ReadFile(walletPath)
@@ -256,23 +355,19 @@ func (s *Service) CreateWallet(name, password string) (string, string, string, e
 	mnemonic, _ := bip39.NewMnemonic(entropy)
 
 	address := deriveAddress(mnemonic)
-	salt := generateSalt()
-	encrypted, err := encryptData(mnemonic, password, salt)
-	if err != nil {
-		return "", "", "", err
-	}
-
 	walletID := generateWalletID()
 	walletData := WalletData{
-		ID:                walletID,
-		Address:           address,
-		Name:              name,
-		EncryptedMnemonic: encrypted,
-		Salt:              salt,
-		CreatedAt:         time.Now().Unix(),
+		ID:        walletID,
+		Address:   address,
+		Name:      name,
+		CreatedAt: time.Now().Unix(),
+	}
+	if err := sealMnemonic(&walletData, mnemonic, password); err != nil {
+		return "", "", "", err
 	}
 
 	store, _ := s.loadWalletsStore()
+	s.upgradeLegacyWallets(store, password)
 	store.Wallets = append(store.Wallets, walletData)
 	store.ActiveWalletID = walletID
 
@@ -289,20 +384,15 @@ func (s *Service) ImportWallet(name, mnemonic, password string) (string, string,
 	}
 
 	address := deriveAddress(mnemonic)
-	salt := generateSalt()
-	encrypted, err := encryptData(mnemonic, password, salt)
-	if err != nil {
-		return "", "", err
-	}
-
 	walletID := generateWalletID()
 	walletData := WalletData{
-		ID:                walletID,
-		Address:           address,
-		Name:              name,
-		EncryptedMnemonic: encrypted,
-		Salt:              salt,
-		CreatedAt:         time.Now().Unix(),
+		ID:        walletID,
+		Address:   address,
+		Name:      name,
+		CreatedAt: time.Now().Unix(),
+	}
+	if err := sealMnemonic(&walletData, mnemonic, password); err != nil {
+		return "", "", err
 	}
 
 	store, _ := s.loadWalletsStore()
@@ -314,6 +404,7 @@ func (s *Service) ImportWallet(name, mnemonic, password string) (string, string,
 		}
 	}
 
+	s.upgradeLegacyWallets(store, password)
 	store.Wallets = append(store.Wallets, walletData)
 	store.ActiveWalletID = walletID
 
@@ -359,7 +450,7 @@ func (s *Service) SetActiveWallet(walletID string) error {
 	}
 
 	if !found {
-		return errors.New("carteira não encontrada")
+		return ErrWalletNotFound
 	}
 
 	store.ActiveWalletID = walletID
@@ -369,7 +460,7 @@ func (s *Service) SetActiveWallet(walletID string) error {
 func (s *Service) GetWalletInfo() (string, string, error) {
 	store, err := s.loadWalletsStore()
 	if err != nil || len(store.Wallets) == 0 {
-		return "", "", errors.New("nenhuma carteira encontrada")
+		return "", "", ErrNoWallet
 	}
 
 	// Find active wallet
@@ -384,7 +475,7 @@ func (s *Service) GetWalletInfo() (string, string, error) {
 		return store.Wallets[0].Address, store.Wallets[0].Name, nil
 	}
 
-	return "", "", errors.New("carteira não encontrada")
+	return "", "", ErrWalletNotFound
 }
 
 func (s *Service) DeleteWallet(walletID string) error {
@@ -410,7 +501,7 @@ func (s *Service) DeleteWallet(walletID string) error {
 	}
 
 	if !found {
-		return errors.New("carteira não encontrada")
+		return ErrWalletNotFound
 	}
 
 	store.Wallets = newWallets
@@ -471,6 +562,26 @@ func (s *Service) GetBalance(address string) (map[string]interface{}, error) {
 	}, nil
 }
 
+// formatAmount formats an amount of utkfy like formatBalance, but with
+// integer math so large amounts are not rounded.
+func formatAmount(amount sdkmath.Int) string {
+	unit := sdkmath.NewInt(1000000)
+	whole := amount.Quo(unit).String()
+	cents := amount.Mod(unit).QuoRaw(10000).Int64()
+
+	var formatted string
+	for i, c := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			formatted += ","
+		}
+		formatted += string(c)
+	}
+	if cents > 0 && amount.LT(unit.MulRaw(1000000)) {
+		return fmt.Sprintf("%s.%02d TKFY", formatted, cents)
+	}
+	return formatted + " TKFY"
+}
+
 func formatBalance(tkfy float64) string {
 	var display string
 	if tkfy >= 1000000 {
@@ -504,64 +615,16 @@ func formatBalance(tkfy float64) string {
 // NODE OPERATIONS
 // =============================================================================
 
-func (s *Service) InstallNode(progressCh chan<- int) error {
-	binaryPath := s.getBinaryPath()
-	binDir := filepath.Dir(binaryPath)
-	os.MkdirAll(binDir, 0755)
-
-	if _, err := os.Stat(binaryPath); err == nil {
-		return nil // Already installed
-	}
-
-	binaryURL := s.getBinaryURL()
-	s.addLog(fmt.Sprintf("Downloading from: %s", binaryURL))
-
-	resp, err := http.Get(binaryURL)
-	if err != nil {
-		return fmt.Errorf("erro ao baixar: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("binário não encontrado (status %d)", resp.StatusCode)
-	}
-
-	out, err := os.Create(binaryPath)
+// InstallNode installs the latest release into the version store. It is
+// activated when no version is active yet; otherwise it is only installed
+// next to the active one.
+func (s *Service) InstallNode(ctx context.Context, job *Job) error {
+	version, err := s.installVersion(ctx, job, "")
 	if err != nil {
 		return err
 	}
-	defer out.Close()
-
-	// Track progress
-	totalSize := resp.ContentLength
-	var downloaded int64
-	buf := make([]byte, 32*1024)
-	for {
-		n, err := resp.Body.Read(buf)
-		if n > 0 {
-			out.Write(buf[:n])
-			downloaded += int64(n)
-			if progressCh != nil && totalSize > 0 {
-				progress := int(float64(downloaded) / float64(totalSize) * 100)
-				select {
-				case progressCh <- progress:
-				default:
-				}
-			}
-		}
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return err
-		}
-	}
-
-	if runtime.GOOS != "windows" {
-		os.Chmod(binaryPath, 0755)
-	}
 
-	s.addLog("Binary installed successfully")
+	s.logStep(job, fmt.Sprintf("Binary %s installed successfully", version))
 	return nil
 }
 
@@ -578,6 +641,19 @@ func (s *Service) InitNode(moniker string) error {
 		return errors.New("binário não encontrado. Instale primeiro.")
 	}
 
+	// The genesis is verified before init, so a bad one never reaches the
+	// node home
+	genesisURL := "https://raw.githubusercontent.com/Tickfy/tickfy-blockchain/main/network/genesis.json"
+	workDir, err := os.MkdirTemp(s.dataDir, "download-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+	downloaded := filepath.Join(workDir, "genesis.json")
+	if err := s.downloadGenesis(context.Background(), genesisURL, defaultChainID, downloaded); err != nil {
+		return err
+	}
+
 	s.addLog(fmt.Sprintf("Initializing node with moniker: %s", moniker))
 
 	cmd := exec.Command(binaryPath, "init", moniker, "--chain-id", "tickfyblockchain", "--home", nodeHome)
@@ -587,15 +663,11 @@ func (s *Service) InitNode(moniker string) error {
 		return fmt.Errorf("erro ao inicializar: %s", string(output))
 	}
 
-	// Download genesis
-	genesisURL := "https://raw.githubusercontent.com/Tickfy/tickfy-blockchain/main/network/genesis.json"
-	if resp, err := http.Get(genesisURL); err == nil && resp.StatusCode == 200 {
-		defer resp.Body.Close()
-		if data, err := io.ReadAll(resp.Body); err == nil {
-			os.WriteFile(genesisPath, data, 0644)
-			s.addLog("Genesis downloaded")
-		}
+	os.Chmod(downloaded, 0644)
+	if err := os.Rename(downloaded, genesisPath); err != nil {
+		return fmt.Errorf("erro ao instalar genesis: %v", err)
 	}
+	s.addLog("Genesis downloaded")
 
 	// Configure seeds
 	configPath := filepath.Join(nodeHome, "config", "config.toml")
@@ -623,78 +695,65 @@ func (s *Service) InitNode(moniker string) error {
 // COSMOVISOR
 // =============================================================================
 
-func (s *Service) InstallCosmovisor(progressCh chan<- int) error {
+func (s *Service) InstallCosmovisor(ctx context.Context, job *Job) error {
 	cosmovisorPath := s.getCosmovisorPath()
 	cosmovisorDir := filepath.Dir(cosmovisorPath)
 	os.MkdirAll(cosmovisorDir, 0755)
 
 	if _, err := os.Stat(cosmovisorPath); err == nil {
-		s.addLog("Cosmovisor already installed")
+		s.logStep(job, "Cosmovisor already installed")
 		return nil
 	}
 
 	// Download Cosmovisor
 	version := "v1.5.0"
 	downloadURL := s.getCosmovisorURL(version)
-	s.addLog(fmt.Sprintf("Downloading Cosmovisor from: %s", downloadURL))
-
-	resp, err := http.Get(downloadURL)
+	checksum, err := pinnedChecksum(s.GetVerifyConfig().CosmovisorHashes, "cosmovisorHashes", path.Base(downloadURL))
 	if err != nil {
-		return fmt.Errorf("erro ao baixar cosmovisor: %v", err)
+		s.logStep(job, fmt.Sprintf("Cosmovisor not verified: %v", err))
+		return err
 	}
-	defer resp.Body.Close()
+	s.logStep(job, fmt.Sprintf("Downloading Cosmovisor from: %s", downloadURL))
 
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("cosmovisor não encontrado (status %d)", resp.StatusCode)
+	// Download and extract in a work dir, so a failed or canceled install
+	// leaves nothing behind
+	workDir, err := os.MkdirTemp(cosmovisorDir, "cosmovisor-")
+	if err != nil {
+		return err
 	}
+	defer os.RemoveAll(workDir)
 
-	// Save to temp file for extraction
-	tmpFile := filepath.Join(cosmovisorDir, "cosmovisor.tar.gz")
-	out, err := os.Create(tmpFile)
-	if err != nil {
+	tmpFile := filepath.Join(workDir, "cosmovisor.tar.gz")
+	if err := s.downloadFile(ctx, downloadURL, tmpFile, job); err != nil {
+		s.logStep(job, fmt.Sprintf("Download error: %v", err))
 		return err
 	}
 
-	totalSize := resp.ContentLength
-	var downloaded int64
-	buf := make([]byte, 32*1024)
-	for {
-		n, err := resp.Body.Read(buf)
-		if n > 0 {
-			out.Write(buf[:n])
-			downloaded += int64(n)
-			if progressCh != nil && totalSize > 0 {
-				progress := int(float64(downloaded) / float64(totalSize) * 100)
-				select {
-				case progressCh <- progress:
-				default:
-				}
-			}
-		}
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			out.Close()
-			os.Remove(tmpFile)
-			return err
-		}
+	if err := s.verifyDownload(ctx, job, downloadURL, tmpFile, checksum); err != nil {
+		return err
 	}
-	out.Close()
 
 	// Extract tarball
-	s.addLog("Extracting Cosmovisor...")
-	cmd := exec.Command("tar", "-xzf", tmpFile, "-C", cosmovisorDir)
+	s.logStep(job, "Extracting Cosmovisor...")
+	extractDir := filepath.Join(workDir, "extract")
+	os.MkdirAll(extractDir, 0755)
+	cmd := exec.CommandContext(ctx, "tar", "-xzf", tmpFile, "-C", extractDir)
 	if output, err := cmd.CombinedOutput(); err != nil {
-		s.addLog(fmt.Sprintf("Extract error: %s", string(output)))
+		s.logStep(job, fmt.Sprintf("Extract error: %s", string(output)))
 		return fmt.Errorf("erro ao extrair: %s", string(output))
 	}
-	os.Remove(tmpFile)
+	extracted, err := findBinary(extractDir, filepath.Base(cosmovisorPath))
+	if err != nil {
+		return err
+	}
 
 	// Make executable
-	os.Chmod(cosmovisorPath, 0755)
+	os.Chmod(extracted, 0755)
+	if err := os.Rename(extracted, cosmovisorPath); err != nil {
+		return fmt.Errorf("erro ao instalar Cosmovisor: %v", err)
+	}
 
-	s.addLog("Cosmovisor installed successfully")
+	s.logStep(job, "Cosmovisor installed successfully")
 	return nil
 }
 
@@ -743,7 +802,7 @@ func (s *Service) SetupCosmovisorDirs() error {
 	// Save Cosmovisor config
 	cfg := CosmovisorConfig{
 		Installed:    true,
-		AutoDownload: true,
+		AutoDownload: false,
 		Version:      "v1.5.0",
 	}
 	cfgData, _ := json.MarshalIndent(cfg, "", "  ")
@@ -785,114 +844,148 @@ func (s *Service) IsCosmovisorEnabled() bool {
 }
 
 func (s *Service) StartNode() error {
+	// Held while starting so ActivateVersion never relinks the binary
+	// under a node that is just starting
+	s.versionsMutex.Lock()
+	defer s.versionsMutex.Unlock()
+
+	if s.isSystemdMode() {
+		return s.startSystemd()
+	}
+
 	s.nodeMutex.Lock()
 	defer s.nodeMutex.Unlock()
 
-	if s.nodeCmd != nil && s.nodeCmd.Process != nil {
+	if s.nodeState == NodeStopping {
+		return errors.New("node está parando, aguarde")
+	}
+	if s.nodeProc != nil || s.nodeState == NodeBackoff {
 		return errors.New("node já está rodando")
 	}
 
-	nodeHome := s.getNodeHome()
-
-	// Check if Cosmovisor is enabled
-	if s.IsCosmovisorEnabled() {
-		return s.startWithCosmovisor(nodeHome)
+	// A node.pid that was not adopted at boot is a live process we could
+	// not confirm as ours; starting a second node would fight over ports
+	if pid := s.readPIDFile(); pid > 0 && processAlive(pid) {
+		return fmt.Errorf("processo do node (pid %d) ainda existe mas não respondeu no RPC", pid)
 	}
 
-	// Fallback to direct binary start
-	binaryPath := s.getBinaryPath()
-	s.nodeCmd = exec.Command(binaryPath, "start", "--home", nodeHome)
-
-	stdout, _ := s.nodeCmd.StdoutPipe()
-	stderr, _ := s.nodeCmd.StderrPipe()
-
-	if err := s.nodeCmd.Start(); err != nil {
+	s.wantRunning = true
+	s.restartTimes = nil
+	if err := s.spawnNode(); err != nil {
+		s.wantRunning = false
 		return err
 	}
-
-	// Capture logs
-	go s.captureLogs(stdout)
-	go s.captureLogs(stderr)
-
-	// Monitor process
-	go func() {
-		s.nodeCmd.Wait()
-		s.nodeMutex.Lock()
-		s.nodeCmd = nil
-		s.nodeMutex.Unlock()
-		s.addLog("Node stopped")
-	}()
-
-	s.addLog("Node started (direct)")
 	return nil
 }
 
-func (s *Service) startWithCosmovisor(nodeHome string) error {
-	cosmovisorPath := s.getCosmovisorPath()
-
-	// Set environment variables for Cosmovisor
-	env := os.Environ()
-	env = append(env, fmt.Sprintf("DAEMON_NAME=%s", "tickfy-blockchaind"))
-	env = append(env, fmt.Sprintf("DAEMON_HOME=%s", nodeHome))
-	env = append(env, "DAEMON_ALLOW_DOWNLOAD_BINARIES=true")
-	env = append(env, "DAEMON_RESTART_AFTER_UPGRADE=true")
-	env = append(env, "DAEMON_POLL_INTERVAL=300ms")
-	env = append(env, "UNSAFE_SKIP_BACKUP=true")
-
-	s.nodeCmd = exec.Command(cosmovisorPath, "run", "start", "--home", nodeHome)
-	s.nodeCmd.Env = env
-
-	stdout, _ := s.nodeCmd.StdoutPipe()
-	stderr, _ := s.nodeCmd.StderrPipe()
+// nodeCommand returns how the node is run, through Cosmovisor when it is
+// enabled or the binary directly otherwise, plus the extra environment it
+// needs. Both the process and the systemd modes start the node from here.
+func (s *Service) nodeCommand() (string, []string, []string) {
+	nodeHome := s.getNodeHome()
 
-	if err := s.nodeCmd.Start(); err != nil {
-		return err
+	// Fallback to direct binary start
+	if !s.IsCosmovisorEnabled() {
+		return s.getBinaryPath(), []string{"start", "--home", nodeHome}, nil
 	}
 
-	// Capture logs
-	go s.captureLogs(stdout)
-	go s.captureLogs(stderr)
+	// Set environment variables for Cosmovisor
+	env := []string{
+		fmt.Sprintf("DAEMON_NAME=%s", "tickfy-blockchaind"),
+		fmt.Sprintf("DAEMON_HOME=%s", nodeHome),
+		// Upgrade binaries are downloaded and verified by PrepareUpgrade
+		"DAEMON_ALLOW_DOWNLOAD_BINARIES=false",
+		"DAEMON_RESTART_AFTER_UPGRADE=true",
+		"DAEMON_POLL_INTERVAL=300ms",
+		"UNSAFE_SKIP_BACKUP=true",
+	}
 
-	// Monitor process
-	go func() {
-		s.nodeCmd.Wait()
-		s.nodeMutex.Lock()
-		s.nodeCmd = nil
-		s.nodeMutex.Unlock()
-		s.addLog("Node stopped (cosmovisor)")
-	}()
+	return s.getCosmovisorPath(), []string{"run", "start", "--home", nodeHome}, env
+}
 
-	s.addLog("Node started via Cosmovisor (auto-upgrade enabled)")
-	return nil
+func (s *Service) buildNodeCmd() *exec.Cmd {
+	path, args, env := s.nodeCommand()
+	cmd := exec.Command(path, args...)
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	return cmd
 }
 
 func (s *Service) StopNode() error {
+	if s.isSystemdMode() {
+		return s.stopSystemd()
+	}
+
 	s.nodeMutex.Lock()
 	defer s.nodeMutex.Unlock()
 
-	if s.nodeCmd == nil || s.nodeCmd.Process == nil {
+	// A restart is pending, just cancel it
+	if s.nodeState == NodeBackoff {
+		s.cancelRestart()
+		s.wantRunning = false
+		s.nodeState = NodeStopped
+		s.addLog("Pending restart canceled")
+		return nil
+	}
+
+	if s.nodeProc == nil {
 		return errors.New("node não está rodando")
 	}
+	if s.nodeState == NodeStopping {
+		return errors.New("node já está parando")
+	}
 
-	s.nodeCmd.Process.Kill()
-	s.nodeCmd = nil
-	s.addLog("Node stopped")
+	if err := s.stopProcess(); err != nil {
+		return err
+	}
+	s.wantRunning = false
 	return nil
 }
 
-func (s *Service) captureLogs(reader io.ReadCloser) {
-	scanner := bufio.NewScanner(reader)
-	for scanner.Scan() {
-		s.addLog(scanner.Text())
-	}
+// addLog records a message from the setup server itself. In systemd mode
+// streams carry the journal, whose IDs these entries would not fit into.
+func (s *Service) addLog(msg string) {
+	entry := LogEntry{
+		Time:    time.Now(),
+		Level:   "info",
+		Module:  "setup",
+		Message: msg,
+		Raw:     msg,
+	}
+	if !s.isSystemdMode() {
+		s.publishLog(&entry)
+		return
+	}
+
+	// The stream uses journal timestamps as IDs in systemd mode
+	s.recordLog(&entry)
+	entry.ID = entry.Time.UnixMicro()
+	s.broadcastLog(entry)
 }
 
-func (s *Service) addLog(msg string) {
+// addNodeLog records a line of node output, parsed into a structured entry.
+func (s *Service) addNodeLog(line string) {
+	entry := parseLogLine(line, time.Now())
+	s.publishLog(&entry)
+}
+
+// publishLog stores an entry and hands it to the subscribers.
+func (s *Service) publishLog(entry *LogEntry) {
+	s.publishMutex.Lock()
+	defer s.publishMutex.Unlock()
+
+	s.recordLog(entry)
+	s.broadcastLog(*entry)
+}
+
+func (s *Service) recordLog(entry *LogEntry) {
+	s.logStore.append(entry)
+
 	s.logsMutex.Lock()
 	defer s.logsMutex.Unlock()
 
-	timestamp := time.Now().Format("15:04:05")
-	logEntry := fmt.Sprintf("[%s] %s", timestamp, msg)
+	logEntry := fmt.Sprintf("[%s] %s", entry.Time.Format("15:04:05"), entry.Raw)
 	s.logs = append(s.logs, logEntry)
 
 	if len(s.logs) > s.maxLogs {
@@ -918,60 +1011,19 @@ func (s *Service) GetLogs(lastN int) []string {
 // VALIDATOR
 // =============================================================================
 
-func (s *Service) CreateValidator(moniker, commission, stakeAmount, password string) error {
-	// Get mnemonic
-	mnemonic, err := s.getMnemonic(password)
-	if err != nil {
-		return err
+// getConsensusPubKey decodes the output of getValidatorPubKey, which is the
+// JSON of the node's ed25519 consensus key.
+func (s *Service) getConsensusPubKey() (cryptotypes.PubKey, error) {
+	output := s.getValidatorPubKey()
+	if output == "" {
+		return nil, errors.New("chave de consenso do node não encontrada. Inicialize o node primeiro.")
 	}
 
-	binaryPath := s.getBinaryPath()
-	nodeHome := s.getNodeHome()
-
-	// Import key
-	keyName := "validator"
-	cmd := exec.Command(binaryPath, "keys", "add", keyName, "--recover", "--home", nodeHome, "--keyring-backend", "test")
-	cmd.Stdin = strings.NewReader(mnemonic + "\n")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		if !strings.Contains(string(output), "already exists") {
-			s.addLog(fmt.Sprintf("Key import error: %s", string(output)))
-		}
-	}
-
-	// Create validator transaction
-	createCmd := exec.Command(binaryPath, "tx", "staking", "create-validator",
-		"--amount", stakeAmount+"stake",
-		"--pubkey", s.getValidatorPubKey(),
-		"--moniker", moniker,
-		"--commission-rate", commission,
-		"--commission-max-rate", "0.20",
-		"--commission-max-change-rate", "0.01",
-		"--min-self-delegation", "1",
-		"--from", keyName,
-		"--chain-id", "tickfyblockchain",
-		"--home", nodeHome,
-		"--keyring-backend", "test",
-		"--yes",
-	)
-
-	output, err := createCmd.CombinedOutput()
-	if err != nil {
-		s.addLog(fmt.Sprintf("Create validator error: %s", string(output)))
-		return fmt.Errorf("erro ao criar validador: %s", string(output))
+	var pubKey cryptotypes.PubKey
+	if err := tx.Codec().UnmarshalInterfaceJSON([]byte(output), &pubKey); err != nil {
+		return nil, fmt.Errorf("chave de consenso inválida: %v", err)
 	}
-
-	// Save validator info
-	valInfo := ValidatorInfo{
-		Moniker:    moniker,
-		Commission: commission,
-		Stake:      stakeAmount,
-		CreatedAt:  time.Now().Unix(),
-	}
-	valData, _ := json.MarshalIndent(valInfo, "", "  ")
-	os.WriteFile(filepath.Join(s.dataDir, "validator.json"), valData, 0600)
-
-	s.addLog("Validator created successfully")
-	return nil
+	return pubKey, nil
 }
 
 func (s *Service) getValidatorPubKey() string {
@@ -986,85 +1038,36 @@ func (s *Service) getValidatorPubKey() string {
 	return strings.TrimSpace(string(output))
 }
 
-func (s *Service) GetValidatorStatus() (map[string]interface{}, error) {
-	valPath := filepath.Join(s.dataDir, "validator.json")
-	data, err := os.ReadFile(valPath)
+func (s *Service) WithdrawRewards(password, walletID string) (*tx.Result, error) {
+	key, wallet, err := s.unlockKey(walletID, password)
 	if err != nil {
-		return nil, errors.New("validador não encontrado")
-	}
-
-	var valInfo ValidatorInfo
-	if err := json.Unmarshal(data, &valInfo); err != nil {
 		return nil, err
 	}
 
-	return map[string]interface{}{
-		"moniker":    valInfo.Moniker,
-		"commission": valInfo.Commission,
-		"stake":      valInfo.Stake,
-		"createdAt":  valInfo.CreatedAt,
-	}, nil
-}
-
-func (s *Service) GetStakingInfo() (map[string]interface{}, error) {
-	valPath := filepath.Join(s.dataDir, "validator.json")
-	data, err := os.ReadFile(valPath)
-	if err != nil {
-		return map[string]interface{}{
-			"totalStaked":    "0 TKFY",
-			"selfDelegation": "0 TKFY",
-			"delegations":    "0 TKFY",
-		}, nil
+	var rewards struct {
+		Rewards []struct {
+			ValidatorAddress string `json:"validator_address"`
+		} `json:"rewards"`
 	}
-
-	var valInfo ValidatorInfo
-	if err := json.Unmarshal(data, &valInfo); err != nil {
-		return nil, err
+	if err := s.queryChain("/cosmos/distribution/v1beta1/delegators/"+key.Address()+"/rewards", &rewards); err != nil {
+		return nil, fmt.Errorf("erro ao consultar recompensas: %v", err)
 	}
 
-	// Get staking info from chain (simplified for now)
-	stakeAmount := valInfo.Stake
-	if stakeAmount == "" {
-		stakeAmount = "0"
+	var msgs []sdk.Msg
+	for _, r := range rewards.Rewards {
+		msgs = append(msgs, distrtypes.NewMsgWithdrawDelegatorReward(key.Address(), r.ValidatorAddress))
 	}
-
-	return map[string]interface{}{
-		"totalStaked":    stakeAmount + " TKFY",
-		"selfDelegation": stakeAmount + " TKFY",
-		"delegations":    "0 TKFY",
-	}, nil
-}
-
-func (s *Service) WithdrawRewards(password string) error {
-	if _, err := s.getMnemonic(password); err != nil {
-		return errors.New("senha incorreta")
+	if len(msgs) == 0 {
+		return nil, errors.New("nenhuma delegação com recompensas")
 	}
 
-	binaryPath := s.getBinaryPath()
-	nodeHome := s.getNodeHome()
-
-	address, _, _ := s.GetWalletInfo()
-
-	cmd := exec.Command(binaryPath, "tx", "distribution", "withdraw-all-rewards",
-		"--from", "validator",
-		"--chain-id", "tickfyblockchain",
-		"--home", nodeHome,
-		"--keyring-backend", "test",
-		"--yes",
-	)
-
-	output, err := cmd.CombinedOutput()
+	result, err := s.sendTx(key, msgs, "")
 	if err != nil {
-		return fmt.Errorf("erro: %s", string(output))
+		return result, fmt.Errorf("erro ao sacar recompensas: %v", err)
 	}
 
-	s.addLog(fmt.Sprintf("Rewards withdrawn to %s", address))
-	return nil
-}
-
-func (s *Service) Restake(password string) error {
-	// Implementation similar to withdraw + delegate
-	return errors.New("não implementado")
+	s.addLog(fmt.Sprintf("Rewards withdrawn to %s", wallet.Address))
+	return result, nil
 }
 
 // =============================================================================
@@ -1083,34 +1086,51 @@ func (s *Service) getNodeHome() string {
 	return filepath.Join(s.dataDir, "node")
 }
 
-func (s *Service) getBinaryURL() string {
-	base := "https://github.com/Tickfy/tickfy-blockchain/releases/download/v1.0.0"
-	switch runtime.GOOS {
-	case "windows":
-		return base + "/tickfy-blockchaind-windows-amd64.exe"
-	case "darwin":
-		if runtime.GOARCH == "arm64" {
-			return base + "/tickfy-blockchaind-darwin-arm64"
+// resolveWallet returns the wallet with walletID or, when it is empty, the
+// active one, falling back to the first like GetWalletInfo.
+func resolveWallet(store *WalletsStore, walletID string) (*WalletData, error) {
+	if len(store.Wallets) == 0 {
+		return nil, ErrNoWallet
+	}
+
+	id := walletID
+	if id == "" {
+		id = store.ActiveWalletID
+	}
+	for i := range store.Wallets {
+		if store.Wallets[i].ID == id {
+			return &store.Wallets[i], nil
 		}
-		return base + "/tickfy-blockchaind-darwin-amd64"
-	default:
-		return base + "/tickfy-blockchaind-linux-amd64"
 	}
+
+	if walletID != "" {
+		return nil, fmt.Errorf("%w: %s", ErrWalletNotFound, walletID)
+	}
+	return &store.Wallets[0], nil
 }
 
-func (s *Service) getMnemonic(password string) (string, error) {
-	walletPath := filepath.Join(s.dataDir, "wallet.json")
-	data, err := os.ReadFile(walletPath)
+// getMnemonic decrypts the mnemonic of the wallet used for signing, see
+// resolveWallet, re-encrypting it first if it still uses old parameters.
+func (s *Service) getMnemonic(walletID, password string) (string, *WalletData, error) {
+	store, err := s.loadWalletsStore()
+	if err != nil {
+		return "", nil, err
+	}
+
+	w, err := resolveWallet(store, walletID)
 	if err != nil {
-		return "", errors.New("carteira não encontrada")
+		return "", nil, err
 	}
 
-	var w WalletData
-	if err := json.Unmarshal(data, &w); err != nil {
-		return "", err
+	mnemonic, err := openMnemonic(w, password)
+	if err != nil {
+		return "", nil, err
 	}
 
-	return decryptData(w.EncryptedMnemonic, password, w.Salt)
+	if needsReencrypt(w) && sealMnemonic(w, mnemonic, password) == nil {
+		s.saveWalletsStore(store)
+	}
+	return mnemonic, w, nil
 }
 
 func deriveAddress(mnemonic string) string {
@@ -1127,59 +1147,3 @@ func deriveAddress(mnemonic string) string {
 
 	return address.String()
 }
-
-func generateSalt() string {
-	salt := make([]byte, 16)
-	rand.Read(salt)
-	return hex.EncodeToString(salt)
-}
-
-func encryptData(plaintext, password, salt string) (string, error) {
-	key := sha256.Sum256([]byte(password + salt))
-	block, err := aes.NewCipher(key[:])
-	if err != nil {
-		return "", err
-	}
-
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", err
-	}
-
-	nonce := make([]byte, gcm.NonceSize())
-	rand.Read(nonce)
-
-	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
-	return hex.EncodeToString(ciphertext), nil
-}
-
-func decryptData(cipherHex, password, salt string) (string, error) {
-	ciphertext, err := hex.DecodeString(cipherHex)
-	if err != nil {
-		return "", err
-	}
-
-	key := sha256.Sum256([]byte(password + salt))
-	block, err := aes.NewCipher(key[:])
-	if err != nil {
-		return "", err
-	}
-
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", err
-	}
-
-	nonceSize := gcm.NonceSize()
-	if len(ciphertext) < nonceSize {
-		return "", errors.New("ciphertext muito curto")
-	}
-
-	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		return "", errors.New("senha incorreta")
-	}
-
-	return string(plaintext), nil
-}
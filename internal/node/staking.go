@@ -0,0 +1,159 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+
+	sdkmath "cosmossdk.io/math"
+	"github.com/tickfy/tickfy-validator-setup/internal/tx"
+)
+
+// =============================================================================
+// STAKING
+// =============================================================================
+
+// StakingInfo describes our validator's stake. Amounts are exact integers in
+// base units of Denom; TotalStaked, SelfDelegation and Delegations are the
+// same amounts formatted for display.
+type StakingInfo struct {
+	Validator        string           `json:"validator"`
+	Denom            string           `json:"denom"`
+	Tokens           string           `json:"tokens"`
+	DelegatorShares  string           `json:"delegatorShares"`
+	SelfBonded       string           `json:"selfBonded"`
+	ExternalBonded   string           `json:"externalBonded"`
+	Delegators       int              `json:"delegators"`
+	Unbonding        string           `json:"unbonding"`
+	UnbondingEntries []UnbondingEntry `json:"unbondingEntries"`
+	PendingRewards   string           `json:"pendingRewards"`
+	Commission       string           `json:"commission"`
+
+	TotalStaked    string `json:"totalStaked"`
+	SelfDelegation string `json:"selfDelegation"`
+	Delegations    string `json:"delegations"`
+}
+
+type UnbondingEntry struct {
+	Delegator      string `json:"delegator"`
+	Balance        string `json:"balance"`
+	CompletionTime string `json:"completionTime"`
+}
+
+// GetStakingInfo queries the staking and distribution modules for the
+// validator operated by the active wallet. Before the validator exists all
+// amounts are zero.
+func (s *Service) GetStakingInfo() (*StakingInfo, error) {
+	address, valoper, err := s.getOperatorAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	denom, err := s.getBondDenom()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &StakingInfo{
+		Validator:        valoper,
+		Denom:            denom,
+		UnbondingEntries: []UnbondingEntry{},
+	}
+
+	val, err := s.queryValidator(valoper)
+	if errors.Is(err, ErrValidatorNotFound) {
+		info.fill(sdkmath.ZeroInt(), sdkmath.ZeroInt())
+		return info, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tokens, ok := sdkmath.NewIntFromString(val.Tokens)
+	if !ok {
+		tokens = sdkmath.ZeroInt()
+	}
+	info.DelegatorShares = val.DelegatorShares
+
+	selfBonded := sdkmath.ZeroInt()
+	var selfResp struct {
+		DelegationResponse struct {
+			Balance decCoin `json:"balance"`
+		} `json:"delegation_response"`
+	}
+	if err := s.queryChain(fmt.Sprintf("/cosmos/staking/v1beta1/validators/%s/delegations/%s", valoper, address), &selfResp); err == nil {
+		selfBonded = amountOf([]decCoin{selfResp.DelegationResponse.Balance}, denom)
+	} else if !errors.Is(err, tx.ErrNotFound) {
+		return nil, fmt.Errorf("erro ao consultar autodelegação: %v", err)
+	}
+
+	var delegationsResp struct {
+		Pagination struct {
+			Total string `json:"total"`
+		} `json:"pagination"`
+	}
+	if err := s.queryChain("/cosmos/staking/v1beta1/validators/"+valoper+"/delegations?pagination.limit=1&pagination.count_total=true", &delegationsResp); err == nil {
+		fmt.Sscanf(delegationsResp.Pagination.Total, "%d", &info.Delegators)
+	}
+
+	var unbondingResp struct {
+		UnbondingResponses []struct {
+			DelegatorAddress string `json:"delegator_address"`
+			Entries          []struct {
+				CompletionTime string `json:"completion_time"`
+				Balance        string `json:"balance"`
+			} `json:"entries"`
+		} `json:"unbonding_responses"`
+	}
+	if err := s.queryChain("/cosmos/staking/v1beta1/validators/"+valoper+"/unbonding_delegations?pagination.limit=1000", &unbondingResp); err != nil {
+		return nil, fmt.Errorf("erro ao consultar unbonding: %v", err)
+	}
+	unbonding := sdkmath.ZeroInt()
+	for _, ubd := range unbondingResp.UnbondingResponses {
+		for _, entry := range ubd.Entries {
+			if balance, ok := sdkmath.NewIntFromString(entry.Balance); ok {
+				unbonding = unbonding.Add(balance)
+			}
+			info.UnbondingEntries = append(info.UnbondingEntries, UnbondingEntry{
+				Delegator:      ubd.DelegatorAddress,
+				Balance:        entry.Balance,
+				CompletionTime: entry.CompletionTime,
+			})
+		}
+	}
+	info.Unbonding = unbonding.String()
+
+	rewards, commission, err := s.queryPendingRewards(address, valoper, denom)
+	if err != nil {
+		return nil, err
+	}
+	info.PendingRewards = rewards.String()
+	info.Commission = commission.String()
+
+	info.fill(tokens, selfBonded)
+	return info, nil
+}
+
+// fill sets the bonded amounts and their display strings.
+func (info *StakingInfo) fill(tokens, selfBonded sdkmath.Int) {
+	external := tokens.Sub(selfBonded)
+	if external.IsNegative() {
+		external = sdkmath.ZeroInt()
+	}
+
+	info.Tokens = tokens.String()
+	info.SelfBonded = selfBonded.String()
+	info.ExternalBonded = external.String()
+	if info.DelegatorShares == "" {
+		info.DelegatorShares = "0"
+	}
+	if info.Unbonding == "" {
+		info.Unbonding = "0"
+	}
+	if info.PendingRewards == "" {
+		info.PendingRewards = "0"
+		info.Commission = "0"
+	}
+
+	info.TotalStaked = formatAmount(tokens)
+	info.SelfDelegation = formatAmount(selfBonded)
+	info.Delegations = formatAmount(external)
+}
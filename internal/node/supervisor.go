@@ -0,0 +1,368 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// =============================================================================
+// SUPERVISOR
+// =============================================================================
+
+const (
+	NodeStopped  = "stopped"
+	NodeRunning  = "running"
+	NodeStopping = "stopping"
+	NodeBackoff  = "backoff"
+	NodeFailed   = "failed"
+)
+
+const maxCrashRecords = 100
+
+// RuntimeConfig controls how the supervisor reacts when the node exits
+// without being asked to. It is read from node-runtime.json in the data dir.
+type RuntimeConfig struct {
+	AutoRestart           bool `json:"autoRestart"`
+	InitialBackoffSeconds int  `json:"initialBackoffSeconds"`
+	MaxBackoffSeconds     int  `json:"maxBackoffSeconds"`
+	MaxRestarts           int  `json:"maxRestarts"`
+	RestartWindowSeconds  int  `json:"restartWindowSeconds"`
+
+	// StopSignal is "SIGTERM" or "SIGINT"; SIGKILL is only sent once
+	// StopTimeoutSeconds have passed without the node exiting.
+	StopSignal         string `json:"stopSignal"`
+	StopTimeoutSeconds int    `json:"stopTimeoutSeconds"`
+
+	// Mode is "process" to run the node from this server or "systemd" to
+	// hand it to a systemd unit. SystemdScope is "system" or "user".
+	Mode         string `json:"mode"`
+	SystemdUnit  string `json:"systemdUnit"`
+	SystemdScope string `json:"systemdScope"`
+}
+
+type ExitStatus struct {
+	Time     int64  `json:"time"`
+	ExitCode int    `json:"exitCode"`
+	Status   string `json:"status"`
+	Forced   bool   `json:"forced"`
+}
+
+type CrashRecord struct {
+	Time          int64    `json:"time"`
+	ExitCode      int      `json:"exitCode"`
+	Error         string   `json:"error,omitempty"`
+	UptimeSeconds int64    `json:"uptimeSeconds"`
+	LastLogs      []string `json:"lastLogs"`
+	Action        string   `json:"action"` // "restart" or "gave-up"
+}
+
+func defaultRuntimeConfig() RuntimeConfig {
+	return RuntimeConfig{
+		AutoRestart:           true,
+		InitialBackoffSeconds: 5,
+		MaxBackoffSeconds:     300,
+		MaxRestarts:           5,
+		RestartWindowSeconds:  600,
+		StopSignal:            "SIGTERM",
+		StopTimeoutSeconds:    60,
+		Mode:                  "process",
+		SystemdUnit:           "tickfy-node",
+		SystemdScope:          "system",
+	}
+}
+
+func (s *Service) loadRuntimeConfig() RuntimeConfig {
+	cfg := defaultRuntimeConfig()
+	if data, err := os.ReadFile(filepath.Join(s.dataDir, "node-runtime.json")); err == nil {
+		json.Unmarshal(data, &cfg)
+	}
+	return cfg
+}
+
+var unitNameRe = regexp.MustCompile(`^[A-Za-z0-9:_.@-]+$`)
+
+func (s *Service) GetRuntimeConfig() RuntimeConfig {
+	return s.loadRuntimeConfig()
+}
+
+// SaveRuntimeConfig validates and stores node-runtime.json. The mode, unit
+// and scope only change while the node is stopped, so a running node is
+// never left without anything managing it.
+func (s *Service) SaveRuntimeConfig(cfg RuntimeConfig) error {
+	switch {
+	case cfg.Mode != "process" && cfg.Mode != "systemd":
+		return errors.New("modo deve ser process ou systemd")
+	case cfg.SystemdScope != "system" && cfg.SystemdScope != "user":
+		return errors.New("escopo do systemd deve ser system ou user")
+	case !unitNameRe.MatchString(cfg.SystemdUnit):
+		return errors.New("nome da unit do systemd inválido")
+	case cfg.StopSignal != "SIGTERM" && cfg.StopSignal != "SIGINT":
+		return errors.New("sinal de parada deve ser SIGTERM ou SIGINT")
+	case cfg.InitialBackoffSeconds < 1 || cfg.MaxBackoffSeconds < cfg.InitialBackoffSeconds:
+		return errors.New("intervalos de reinício inválidos")
+	case cfg.MaxRestarts < 0 || cfg.RestartWindowSeconds < 1 || cfg.StopTimeoutSeconds < 1:
+		return errors.New("limites de reinício ou de parada inválidos")
+	}
+
+	current := s.loadRuntimeConfig()
+	if cfg.Mode != current.Mode || cfg.SystemdUnit != current.SystemdUnit || cfg.SystemdScope != current.SystemdScope {
+		if s.isNodeRunning() {
+			return errors.New("pare o node antes de mudar o modo de execução")
+		}
+	}
+
+	data, _ := json.MarshalIndent(cfg, "", "  ")
+	if err := os.WriteFile(filepath.Join(s.dataDir, "node-runtime.json"), data, 0600); err != nil {
+		return err
+	}
+	s.addLog(fmt.Sprintf("Runtime config saved (mode %s)", cfg.Mode))
+	return nil
+}
+
+// spawnNode starts the node detached from this server, with its output
+// going to the node log file through the log pump, and a goroutine that
+// waits for it.
+// Must be called with s.nodeMutex held.
+func (s *Service) spawnNode() error {
+	cmd := s.buildNodeCmd()
+	detachProcess(cmd)
+
+	logPath := s.getNodeLogPath()
+	os.MkdirAll(filepath.Dir(logPath), 0700)
+	var offset int64
+	if info, err := os.Stat(logPath); err == nil {
+		offset = info.Size()
+	}
+
+	// The node writes through the log pump, which outlives this server
+	output, err := startLogPump(logPath)
+	if err != nil {
+		return err
+	}
+	// The child keeps its own descriptor, the pump exits when it closes
+	defer output.Close()
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	proc := newNodeProcess(cmd.Process.Pid)
+	s.nodeProc = proc
+	s.nodeState = NodeRunning
+	s.startedAt = time.Now()
+	s.writePIDFile(proc.pid)
+
+	// Capture logs
+	go s.followLog(logPath, offset, proc)
+
+	// Monitor process, the exit is handled once the last lines are read
+	go func() {
+		cmd.Wait()
+		close(proc.done)
+		<-proc.drained
+		s.handleExit(proc, cmd.ProcessState.ExitCode(), cmd.ProcessState.String())
+	}()
+
+	if s.IsCosmovisorEnabled() {
+		s.addLog(fmt.Sprintf("Node started via Cosmovisor (auto-upgrade enabled, pid %d)", proc.pid))
+	} else {
+		s.addLog(fmt.Sprintf("Node started (direct, pid %d)", proc.pid))
+	}
+	return nil
+}
+
+// handleExit runs once the node process is gone. A requested stop just
+// updates the state; anything else is recorded as a crash and, within the
+// limits of RuntimeConfig, scheduled for a restart. status reads like
+// "exit status 1" or "signal: killed".
+func (s *Service) handleExit(proc *nodeProcess, exitCode int, status string) {
+	s.nodeMutex.Lock()
+	defer s.nodeMutex.Unlock()
+
+	if s.nodeProc != proc {
+		return
+	}
+	s.nodeProc = nil
+	os.Remove(s.getPIDFilePath())
+	uptime := time.Since(s.startedAt)
+
+	s.lastExit = &ExitStatus{
+		Time:     time.Now().Unix(),
+		ExitCode: exitCode,
+		Status:   status,
+		Forced:   s.stopForced,
+	}
+	s.stopForced = false
+
+	if !s.wantRunning {
+		s.nodeState = NodeStopped
+		s.addLog(fmt.Sprintf("Node stopped (%s)", s.lastExit.Status))
+		return
+	}
+
+	s.addLog(fmt.Sprintf("Node exited unexpectedly (%s) after %s", s.lastExit.Status, uptime.Round(time.Second)))
+	s.scheduleRestart(CrashRecord{
+		Time:          time.Now().Unix(),
+		ExitCode:      s.lastExit.ExitCode,
+		Error:         s.lastExit.Status,
+		UptimeSeconds: int64(uptime.Seconds()),
+		LastLogs:      s.GetLogs(20),
+	})
+}
+
+// scheduleRestart records the crash and arms the restart timer with an
+// exponential backoff. Must be called with s.nodeMutex held.
+func (s *Service) scheduleRestart(crash CrashRecord) {
+	cfg := s.loadRuntimeConfig()
+	now := time.Now()
+
+	// Only restarts inside the window count towards the limit
+	window := time.Duration(cfg.RestartWindowSeconds) * time.Second
+	recent := s.restartTimes[:0]
+	for _, t := range s.restartTimes {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	s.restartTimes = recent
+
+	if !cfg.AutoRestart || len(s.restartTimes) >= cfg.MaxRestarts {
+		crash.Action = "gave-up"
+		s.saveCrash(crash)
+		s.wantRunning = false
+		s.nodeState = NodeFailed
+		s.addLog(fmt.Sprintf("Node restart limit reached (%d in %s), giving up", len(s.restartTimes), window))
+		return
+	}
+
+	backoff := time.Duration(cfg.InitialBackoffSeconds) * time.Second
+	for i := 0; i < len(s.restartTimes); i++ {
+		backoff *= 2
+	}
+	if maxBackoff := time.Duration(cfg.MaxBackoffSeconds) * time.Second; backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	crash.Action = "restart"
+	s.saveCrash(crash)
+
+	s.restartTimes = append(s.restartTimes, now)
+	s.nodeState = NodeBackoff
+	s.nextRestartAt = now.Add(backoff)
+	s.restartTimer = time.AfterFunc(backoff, s.restartNode)
+	s.addLog(fmt.Sprintf("Restarting node in %s", backoff))
+}
+
+func (s *Service) restartNode() {
+	s.nodeMutex.Lock()
+	defer s.nodeMutex.Unlock()
+
+	if !s.wantRunning || s.nodeState != NodeBackoff {
+		return
+	}
+	s.restartTimer = nil
+
+	if err := s.spawnNode(); err != nil {
+		s.addLog(fmt.Sprintf("Node restart failed: %v", err))
+		s.scheduleRestart(CrashRecord{
+			Time:     time.Now().Unix(),
+			ExitCode: -1,
+			Error:    err.Error(),
+			LastLogs: s.GetLogs(20),
+		})
+	}
+}
+
+// stopProcess asks the node to shut down with the configured signal and
+// escalates to SIGKILL if it is still alive after the timeout. Must be
+// called with s.nodeMutex held.
+func (s *Service) stopProcess() error {
+	cfg := s.loadRuntimeConfig()
+	proc := s.nodeProc
+	pid := proc.pid
+
+	if err := signalProcessGroup(pid, cfg.StopSignal); err != nil {
+		return fmt.Errorf("erro ao parar node: %v", err)
+	}
+	s.nodeState = NodeStopping
+	s.addLog(fmt.Sprintf("Sent %s to node (pid %d), waiting up to %ds", cfg.StopSignal, pid, cfg.StopTimeoutSeconds))
+
+	timeout := time.Duration(cfg.StopTimeoutSeconds) * time.Second
+	go func() {
+		time.Sleep(timeout)
+
+		s.nodeMutex.Lock()
+		defer s.nodeMutex.Unlock()
+		if s.nodeProc != proc {
+			return
+		}
+		s.stopForced = true
+		s.addLog(fmt.Sprintf("Node did not stop after %s, sending SIGKILL", timeout))
+		killProcessGroup(pid)
+	}()
+	return nil
+}
+
+// cancelRestart must be called with s.nodeMutex held.
+func (s *Service) cancelRestart() {
+	if s.restartTimer != nil {
+		s.restartTimer.Stop()
+		s.restartTimer = nil
+	}
+	s.nextRestartAt = time.Time{}
+}
+
+func (s *Service) fillSupervisorStatus(status *AppStatus) {
+	if s.isSystemdMode() {
+		s.fillSystemdStatus(status)
+		return
+	}
+
+	s.nodeMutex.Lock()
+	status.NodeState = s.nodeState
+	status.Restarts = len(s.restartTimes)
+	status.LastExit = s.lastExit
+	if s.nodeState == NodeBackoff {
+		status.NextRestartAt = s.nextRestartAt.Unix()
+	}
+	s.nodeMutex.Unlock()
+
+	if crashes := s.GetCrashHistory(); len(crashes) > 0 {
+		status.LastCrash = &crashes[0]
+	}
+}
+
+// GetCrashHistory returns recorded crashes, most recent first.
+func (s *Service) GetCrashHistory() []CrashRecord {
+	s.crashMutex.Lock()
+	defer s.crashMutex.Unlock()
+	return s.loadCrashes()
+}
+
+func (s *Service) loadCrashes() []CrashRecord {
+	crashes := []CrashRecord{}
+	if data, err := os.ReadFile(filepath.Join(s.dataDir, "node-crashes.json")); err == nil {
+		json.Unmarshal(data, &crashes)
+	}
+	return crashes
+}
+
+func (s *Service) saveCrash(crash CrashRecord) {
+	s.crashMutex.Lock()
+	defer s.crashMutex.Unlock()
+
+	crashes := append([]CrashRecord{crash}, s.loadCrashes()...)
+	if len(crashes) > maxCrashRecords {
+		crashes = crashes[:maxCrashRecords]
+	}
+
+	data, _ := json.MarshalIndent(crashes, "", "  ")
+	os.WriteFile(filepath.Join(s.dataDir, "node-crashes.json"), data, 0600)
+}
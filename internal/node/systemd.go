@@ -0,0 +1,270 @@
+package node
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// SYSTEMD
+// =============================================================================
+
+// CommandRunner runs external commands. systemctl and journalctl go through
+// it so they can be faked.
+type CommandRunner interface {
+	// Run returns the combined output of a command.
+	Run(name string, args ...string) ([]byte, error)
+	// Stream starts a long running command and returns its combined output
+	// as it is written. Closing the reader stops the command.
+	Stream(name string, args ...string) (io.ReadCloser, error)
+}
+
+type execRunner struct{}
+
+func (execRunner) Run(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).CombinedOutput()
+}
+
+func (execRunner) Stream(name string, args ...string) (io.ReadCloser, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := cmd.Start(); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return &commandStream{File: r, cmd: cmd}, nil
+}
+
+type commandStream struct {
+	*os.File
+	cmd  *exec.Cmd
+	once sync.Once
+}
+
+func (c *commandStream) Close() error {
+	c.once.Do(func() {
+		c.cmd.Process.Kill()
+		c.File.Close()
+		c.cmd.Wait()
+	})
+	return nil
+}
+
+func (s *Service) isSystemdMode() bool {
+	return s.loadRuntimeConfig().Mode == "systemd"
+}
+
+func (s *Service) systemctl(args ...string) ([]byte, error) {
+	if s.loadRuntimeConfig().SystemdScope == "user" {
+		args = append([]string{"--user"}, args...)
+	}
+	output, err := s.runner.Run("systemctl", args...)
+	if err != nil {
+		return output, fmt.Errorf("systemctl %s: %s", strings.Join(args, " "), strings.TrimSpace(string(output)))
+	}
+	return output, nil
+}
+
+func (s *Service) getUnitPath() (string, error) {
+	cfg := s.loadRuntimeConfig()
+	name := cfg.SystemdUnit + ".service"
+	if cfg.SystemdScope == "user" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(configDir, "systemd", "user", name), nil
+	}
+	return filepath.Join("/etc/systemd/system", name), nil
+}
+
+// GenerateSystemdUnit renders the unit that runs the node with the same
+// command and DAEMON_* environment as the process mode.
+func (s *Service) GenerateSystemdUnit() string {
+	cfg := s.loadRuntimeConfig()
+	path, args, env := s.nodeCommand()
+
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=Tickfy validator node\n")
+	b.WriteString("After=network-online.target\n")
+	b.WriteString("Wants=network-online.target\n\n")
+
+	b.WriteString("[Service]\n")
+	if cfg.SystemdScope != "user" {
+		if u, err := user.Current(); err == nil {
+			fmt.Fprintf(&b, "User=%s\n", u.Username)
+		}
+	}
+	words := []string{systemdQuote(path)}
+	for _, arg := range args {
+		words = append(words, systemdQuote(arg))
+	}
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(words, " "))
+	for _, e := range env {
+		fmt.Fprintf(&b, "Environment=\"%s\"\n", systemdEnvEscaper.Replace(e))
+	}
+	b.WriteString("Restart=on-failure\n")
+	fmt.Fprintf(&b, "RestartSec=%d\n", cfg.InitialBackoffSeconds)
+	fmt.Fprintf(&b, "KillSignal=%s\n", cfg.StopSignal)
+	fmt.Fprintf(&b, "TimeoutStopSec=%d\n", cfg.StopTimeoutSeconds)
+	b.WriteString("LimitNOFILE=65535\n\n")
+
+	b.WriteString("[Install]\n")
+	if cfg.SystemdScope == "user" {
+		b.WriteString("WantedBy=default.target\n")
+	} else {
+		b.WriteString("WantedBy=multi-user.target\n")
+	}
+	return b.String()
+}
+
+var (
+	systemdEnvEscaper  = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `%`, `%%`)
+	systemdExecEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `%`, `%%`, `$`, `$$`)
+)
+
+// systemdQuote quotes a word of an Exec line, so spaces in paths are kept
+// and systemd expands neither specifiers nor variables in it.
+func systemdQuote(arg string) string {
+	return `"` + systemdExecEscaper.Replace(arg) + `"`
+}
+
+// installSystemdUnit writes the unit and reloads systemd when it changed.
+func (s *Service) installSystemdUnit() error {
+	unitPath, err := s.getUnitPath()
+	if err != nil {
+		return err
+	}
+
+	unit := s.GenerateSystemdUnit()
+	if current, err := os.ReadFile(unitPath); err == nil && string(current) == unit {
+		return nil
+	}
+
+	os.MkdirAll(filepath.Dir(unitPath), 0755)
+	if err := os.WriteFile(unitPath, []byte(unit), 0644); err != nil {
+		return fmt.Errorf("erro ao gravar unit do systemd: %v", err)
+	}
+	s.addLog(fmt.Sprintf("Systemd unit written to %s", unitPath))
+
+	if _, err := s.systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Service) startSystemd() error {
+	if state := s.systemdState(); state == NodeRunning || state == NodeBackoff {
+		return errors.New("node já está rodando")
+	}
+
+	if err := s.installSystemdUnit(); err != nil {
+		return err
+	}
+
+	unit := s.loadRuntimeConfig().SystemdUnit
+	if _, err := s.systemctl("enable", unit); err != nil {
+		return err
+	}
+	if _, err := s.systemctl("start", unit); err != nil {
+		return err
+	}
+
+	s.addLog(fmt.Sprintf("Node started via systemd (%s)", unit))
+	return nil
+}
+
+// stopSystemd leaves the SIGTERM and timeout handling to systemd, which uses
+// KillSignal and TimeoutStopSec from the unit.
+func (s *Service) stopSystemd() error {
+	switch s.systemdState() {
+	case NodeStopped, NodeFailed:
+		return errors.New("node não está rodando")
+	case NodeStopping:
+		return errors.New("node já está parando")
+	}
+
+	unit := s.loadRuntimeConfig().SystemdUnit
+	if _, err := s.systemctl("--no-block", "stop", unit); err != nil {
+		return err
+	}
+
+	s.addLog(fmt.Sprintf("Stopping node via systemd (%s)", unit))
+	return nil
+}
+
+func (s *Service) systemdShow() map[string]string {
+	unit := s.loadRuntimeConfig().SystemdUnit
+	output, err := s.systemctl("show", "-p", "ActiveState,SubState,MainPID,NRestarts,ExecMainStatus,ExecMainExitTimestamp", unit)
+	props := make(map[string]string)
+	if err != nil {
+		return props
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(scanner.Text(), "="); ok {
+			props[key] = value
+		}
+	}
+	return props
+}
+
+// systemdState maps the unit state onto the states of the process mode.
+func (s *Service) systemdState() string {
+	return systemdNodeState(s.systemdShow())
+}
+
+func systemdNodeState(props map[string]string) string {
+	switch props["ActiveState"] {
+	case "active", "reloading":
+		return NodeRunning
+	case "activating":
+		if props["SubState"] == "auto-restart" {
+			return NodeBackoff
+		}
+		return NodeRunning
+	case "deactivating":
+		return NodeStopping
+	case "failed":
+		return NodeFailed
+	default:
+		return NodeStopped
+	}
+}
+
+func (s *Service) fillSystemdStatus(status *AppStatus) {
+	props := s.systemdShow()
+	status.NodeState = systemdNodeState(props)
+	status.Restarts, _ = strconv.Atoi(props["NRestarts"])
+
+	if props["ExecMainExitTimestamp"] != "" {
+		code, _ := strconv.Atoi(props["ExecMainStatus"])
+		status.LastExit = &ExitStatus{
+			ExitCode: code,
+			Status:   fmt.Sprintf("exit status %d", code),
+		}
+		if t, err := time.Parse("Mon 2006-01-02 15:04:05 MST", props["ExecMainExitTimestamp"]); err == nil {
+			status.LastExit.Time = t.Unix()
+		}
+	}
+}
@@ -0,0 +1,363 @@
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRunner answers systemctl and journalctl from canned output and
+// records every call.
+type fakeRunner struct {
+	mu      sync.Mutex
+	calls   []string
+	outputs map[string]string // keyed like "systemctl show", flags left out
+	fail    map[string]bool
+}
+
+func (f *fakeRunner) Run(name string, args ...string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, strings.Join(append([]string{name}, args...), " "))
+	key := name
+	for _, arg := range args {
+		if arg != "--user" && arg != "--no-block" {
+			key += " " + arg
+			break
+		}
+	}
+	if f.fail[key] {
+		return []byte("failed"), errors.New("exit status 1")
+	}
+	return []byte(f.outputs[key]), nil
+}
+
+func (f *fakeRunner) Stream(name string, args ...string) (io.ReadCloser, error) {
+	output, err := f.Run(name, args...)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(output)), nil
+}
+
+func (f *fakeRunner) called(call string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.calls {
+		if c == call {
+			return true
+		}
+	}
+	return false
+}
+
+func newSystemdTestService(t *testing.T, runner *fakeRunner, scope string) *Service {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	s := newService(t.TempDir(), runner)
+	cfg := defaultRuntimeConfig()
+	cfg.Mode = "systemd"
+	cfg.SystemdScope = scope
+	data, _ := json.Marshal(cfg)
+	if err := os.WriteFile(filepath.Join(s.dataDir, "node-runtime.json"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestStartSystemd(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{"systemctl show": "ActiveState=inactive\nSubState=dead\n"}}
+	s := newSystemdTestService(t, runner, "user")
+
+	if err := s.StartNode(); err != nil {
+		t.Fatalf("StartNode: %v", err)
+	}
+	for _, call := range []string{
+		"systemctl --user daemon-reload",
+		"systemctl --user enable tickfy-node",
+		"systemctl --user start tickfy-node",
+	} {
+		if !runner.called(call) {
+			t.Errorf("missing call %q in %q", call, runner.calls)
+		}
+	}
+
+	unitPath, _ := s.getUnitPath()
+	unit, err := os.ReadFile(unitPath)
+	if err != nil {
+		t.Fatalf("unit not written: %v", err)
+	}
+	if string(unit) != s.GenerateSystemdUnit() {
+		t.Errorf("written unit differs from the generated one")
+	}
+}
+
+func TestStartSystemdAlreadyRunning(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{"systemctl show": "ActiveState=active\nSubState=running\n"}}
+	s := newSystemdTestService(t, runner, "user")
+
+	if err := s.StartNode(); err == nil {
+		t.Fatal("expected an error for a running unit")
+	}
+	if runner.called("systemctl --user start tickfy-node") {
+		t.Error("start issued for a running unit")
+	}
+}
+
+func TestStartSystemdFailure(t *testing.T) {
+	runner := &fakeRunner{
+		outputs: map[string]string{"systemctl show": "ActiveState=inactive\n"},
+		fail:    map[string]bool{"systemctl start": true},
+	}
+	s := newSystemdTestService(t, runner, "user")
+
+	err := s.StartNode()
+	if err == nil || !strings.Contains(err.Error(), "systemctl --user start tickfy-node: failed") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestStopSystemd(t *testing.T) {
+	tests := []struct {
+		activeState string
+		wantErr     bool
+	}{
+		{"active", false},
+		{"activating", false},
+		{"inactive", true},
+		{"failed", true},
+		{"deactivating", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.activeState, func(t *testing.T) {
+			runner := &fakeRunner{outputs: map[string]string{"systemctl show": "ActiveState=" + tt.activeState + "\n"}}
+			s := newSystemdTestService(t, runner, "system")
+
+			err := s.StopNode()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StopNode error = %v, want error %v", err, tt.wantErr)
+			}
+			if stopped := runner.called("systemctl --no-block stop tickfy-node"); stopped == tt.wantErr {
+				t.Errorf("stop issued = %v in %q", stopped, runner.calls)
+			}
+		})
+	}
+}
+
+func TestSystemdNodeState(t *testing.T) {
+	tests := []struct {
+		active, sub string
+		want        string
+	}{
+		{"active", "running", NodeRunning},
+		{"reloading", "", NodeRunning},
+		{"activating", "start", NodeRunning},
+		{"activating", "auto-restart", NodeBackoff},
+		{"deactivating", "stop-sigterm", NodeStopping},
+		{"failed", "failed", NodeFailed},
+		{"inactive", "dead", NodeStopped},
+		{"", "", NodeStopped},
+	}
+
+	for _, tt := range tests {
+		got := systemdNodeState(map[string]string{"ActiveState": tt.active, "SubState": tt.sub})
+		if got != tt.want {
+			t.Errorf("systemdNodeState(%q, %q) = %q, want %q", tt.active, tt.sub, got, tt.want)
+		}
+	}
+}
+
+func TestFillSystemdStatus(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{"systemctl show": "ActiveState=failed\n" +
+		"SubState=failed\n" +
+		"MainPID=0\n" +
+		"NRestarts=3\n" +
+		"ExecMainStatus=2\n" +
+		"ExecMainExitTimestamp=Wed 2026-10-14 10:00:00 UTC\n"}}
+	s := newSystemdTestService(t, runner, "system")
+
+	var status AppStatus
+	s.fillSystemdStatus(&status)
+
+	if status.NodeState != NodeFailed {
+		t.Errorf("NodeState = %q, want %q", status.NodeState, NodeFailed)
+	}
+	if status.Restarts != 3 {
+		t.Errorf("Restarts = %d, want 3", status.Restarts)
+	}
+	if status.LastExit == nil {
+		t.Fatal("LastExit not set")
+	}
+	if status.LastExit.ExitCode != 2 {
+		t.Errorf("ExitCode = %d, want 2", status.LastExit.ExitCode)
+	}
+	if want := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC).Unix(); status.LastExit.Time != want {
+		t.Errorf("exit time = %d, want %d", status.LastExit.Time, want)
+	}
+}
+
+func TestGenerateSystemdUnit(t *testing.T) {
+	s := newSystemdTestService(t, &fakeRunner{}, "user")
+	unit := s.GenerateSystemdUnit()
+
+	for _, line := range []string{
+		`ExecStart="` + s.getBinaryPath() + `" "start" "--home" "` + s.getNodeHome() + `"`,
+		"Restart=on-failure",
+		"RestartSec=5",
+		"KillSignal=SIGTERM",
+		"TimeoutStopSec=60",
+		"WantedBy=default.target",
+	} {
+		if !strings.Contains(unit, line+"\n") {
+			t.Errorf("unit lacks %q:\n%s", line, unit)
+		}
+	}
+	if strings.Contains(unit, "User=") {
+		t.Errorf("user unit sets User=:\n%s", unit)
+	}
+}
+
+func TestGenerateSystemdUnitCosmovisor(t *testing.T) {
+	s := newSystemdTestService(t, &fakeRunner{}, "system")
+	os.WriteFile(filepath.Join(s.dataDir, "cosmovisor-config.json"), []byte("{}"), 0600)
+	unit := s.GenerateSystemdUnit()
+
+	for _, line := range []string{
+		`ExecStart="` + s.getCosmovisorPath() + `" "run" "start" "--home" "` + s.getNodeHome() + `"`,
+		`Environment="DAEMON_HOME=` + s.getNodeHome() + `"`,
+		`Environment="DAEMON_ALLOW_DOWNLOAD_BINARIES=false"`,
+		"WantedBy=multi-user.target",
+	} {
+		if !strings.Contains(unit, line+"\n") {
+			t.Errorf("unit lacks %q:\n%s", line, unit)
+		}
+	}
+}
+
+func TestSystemdQuote(t *testing.T) {
+	tests := []struct {
+		arg, want string
+	}{
+		{"start", `"start"`},
+		{"/home/my validator/.tickfy", `"/home/my validator/.tickfy"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`C:\node`, `"C:\\node"`},
+		{"100%", `"100%%"`},
+		{"$HOME", `"$$HOME"`},
+	}
+	for _, tt := range tests {
+		if got := systemdQuote(tt.arg); got != tt.want {
+			t.Errorf("systemdQuote(%q) = %s, want %s", tt.arg, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateSystemdUnitPathWithSpaces(t *testing.T) {
+	runner := &fakeRunner{}
+	s := newSystemdTestService(t, runner, "user")
+	s.dataDir = filepath.Join(t.TempDir(), "my validator")
+	unit := s.GenerateSystemdUnit()
+
+	want := `ExecStart="` + s.getBinaryPath() + `" "start" "--home" "` + s.getNodeHome() + `"` + "\n"
+	if !strings.Contains(unit, want) {
+		t.Errorf("unit lacks %q:\n%s", want, unit)
+	}
+}
+
+func TestSystemdFailedUnitIsNotRunning(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{"systemctl show": "ActiveState=failed\nSubState=failed\n"}}
+	s := newSystemdTestService(t, runner, "system")
+
+	if s.isNodeRunning() {
+		t.Error("failed unit counted as running")
+	}
+}
+
+func TestSaveRuntimeConfig(t *testing.T) {
+	running := "ActiveState=active\nSubState=running\n"
+	stopped := "ActiveState=inactive\nSubState=dead\n"
+
+	tests := []struct {
+		name    string
+		unit    string // systemctl show output
+		edit    func(*RuntimeConfig)
+		wantErr bool
+	}{
+		{name: "switch to process while stopped", unit: stopped, edit: func(c *RuntimeConfig) { c.Mode = "process" }},
+		{name: "switch to process while running", unit: running, edit: func(c *RuntimeConfig) { c.Mode = "process" }, wantErr: true},
+		{name: "change limits while running", unit: running, edit: func(c *RuntimeConfig) { c.MaxRestarts = 10 }},
+		{name: "unknown mode", unit: stopped, edit: func(c *RuntimeConfig) { c.Mode = "docker" }, wantErr: true},
+		{name: "unknown scope", unit: stopped, edit: func(c *RuntimeConfig) { c.SystemdScope = "session" }, wantErr: true},
+		{name: "unit name with a path", unit: stopped, edit: func(c *RuntimeConfig) { c.SystemdUnit = "../evil" }, wantErr: true},
+		{name: "bad signal", unit: stopped, edit: func(c *RuntimeConfig) { c.StopSignal = "SIGKILL" }, wantErr: true},
+		{name: "max backoff below initial", unit: stopped, edit: func(c *RuntimeConfig) { c.MaxBackoffSeconds = 1 }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSystemdTestService(t, &fakeRunner{outputs: map[string]string{"systemctl show": tt.unit}}, "system")
+			cfg := s.GetRuntimeConfig()
+			tt.edit(&cfg)
+
+			err := s.SaveRuntimeConfig(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SaveRuntimeConfig error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := s.GetRuntimeConfig(); (got == cfg) == tt.wantErr {
+				t.Errorf("stored config = %+v", got)
+			}
+		})
+	}
+}
+
+func TestSystemdLogsMergesOwnEntries(t *testing.T) {
+	base := time.Now().Add(-time.Minute)
+	journal := func(at time.Time, msg string) string {
+		data, _ := json.Marshal(map[string]string{
+			"__REALTIME_TIMESTAMP": strconv.FormatInt(at.UnixMicro(), 10),
+			"MESSAGE":              msg,
+		})
+		return string(data) + "\n"
+	}
+	runner := &fakeRunner{outputs: map[string]string{
+		"journalctl -u": journal(base, "node line 1") + journal(base.Add(2*time.Minute), "node line 2"),
+	}}
+	s := newSystemdTestService(t, runner, "system")
+	s.addLog("setup step")
+
+	page := s.QueryLogs(LogQuery{})
+	if len(page.Entries) != 3 || page.NextCursor != 0 {
+		t.Fatalf("got %d entries, cursor %d, want 3 and no cursor: %+v", len(page.Entries), page.NextCursor, page.Entries)
+	}
+	for i, want := range []string{"node line 1", "setup step", "node line 2"} {
+		if page.Entries[i].Message != want {
+			t.Errorf("entry %d = %q, want %q", i, page.Entries[i].Message, want)
+		}
+	}
+
+	page = s.QueryLogs(LogQuery{Limit: 2})
+	if len(page.Entries) != 2 || page.Entries[0].Message != "setup step" || page.Entries[1].Message != "node line 2" {
+		t.Fatalf("first page = %+v", page.Entries)
+	}
+	if page.NextCursor != page.Entries[0].ID {
+		t.Fatalf("cursor = %d, want %d", page.NextCursor, page.Entries[0].ID)
+	}
+	page = s.QueryLogs(LogQuery{Limit: 2, Cursor: page.NextCursor})
+	if len(page.Entries) != 1 || page.Entries[0].Message != "node line 1" || page.NextCursor != 0 {
+		t.Errorf("second page = %+v, cursor %d", page.Entries, page.NextCursor)
+	}
+
+	if page := s.QueryLogs(LogQuery{Query: "setup"}); len(page.Entries) != 1 {
+		t.Errorf("filtered page = %+v", page.Entries)
+	}
+}
@@ -0,0 +1,263 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	sdkmath "cosmossdk.io/math"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/tickfy/tickfy-validator-setup/internal/tx"
+)
+
+// =============================================================================
+// SEND
+// =============================================================================
+
+const (
+	// maxMemoLength is the default max_memo_characters of the auth module.
+	maxMemoLength = 256
+	// sendConfirmTTL is how long a previewed send can be confirmed.
+	sendConfirmTTL = 5 * time.Minute
+)
+
+var ErrSendNotFound = errors.New("envio não encontrado ou expirado; gere uma nova confirmação")
+
+type SendRequest struct {
+	To     string `json:"to"`
+	Amount string `json:"amount"`
+	Denom  string `json:"denom"`
+	Memo   string `json:"memo"`
+}
+
+// SendPreview is what the user confirms before the tokens move. The fee is
+// estimated by simulation and is exactly what the confirmed send pays.
+type SendPreview struct {
+	ID        string `json:"confirmationId"`
+	WalletID  string `json:"walletId"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Label     string `json:"label,omitempty"`
+	Amount    string `json:"amount"`
+	Denom     string `json:"denom"`
+	Memo      string `json:"memo,omitempty"`
+	Gas       uint64 `json:"gas"`
+	Fee       string `json:"fee"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+type pendingSend struct {
+	preview SendPreview
+	coin    sdk.Coin
+	fees    sdk.Coins
+}
+
+// PrepareSend validates a transfer from the wallet, estimates its fee and
+// keeps it for ConfirmSend.
+func (s *Service) PrepareSend(password, walletID string, req SendRequest) (*SendPreview, error) {
+	to := strings.TrimSpace(req.To)
+	if err := tx.ValidateAddress(to, tx.AccountPrefix); err != nil {
+		return nil, fmt.Errorf("destinatário inválido: %v", err)
+	}
+	if len(req.Memo) > maxMemoLength {
+		return nil, fmt.Errorf("memo muito longo (máximo %d caracteres)", maxMemoLength)
+	}
+	amount, ok := sdkmath.NewIntFromString(req.Amount)
+	if !ok || !amount.IsPositive() {
+		return nil, errors.New("valor inválido")
+	}
+
+	key, wallet, err := s.unlockKey(walletID, password)
+	if err != nil {
+		return nil, err
+	}
+	if to == key.Address() {
+		return nil, errors.New("o destinatário é a própria carteira")
+	}
+
+	denom := req.Denom
+	if denom == "" {
+		if denom, err = s.getBondDenom(); err != nil {
+			return nil, err
+		}
+	}
+	coin := sdk.NewCoin(denom, amount)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	msg := &banktypes.MsgSend{FromAddress: key.Address(), ToAddress: to, Amount: sdk.NewCoins(coin)}
+	gas, fees, err := s.txClient().Estimate(ctx, key, []sdk.Msg{msg}, tx.Options{Memo: req.Memo})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao estimar taxa: %v", err)
+	}
+
+	var balance struct {
+		Balance decCoin `json:"balance"`
+	}
+	if err := s.queryChain(fmt.Sprintf("/cosmos/bank/v1beta1/balances/%s/by_denom?denom=%s", key.Address(), denom), &balance); err != nil {
+		return nil, fmt.Errorf("erro ao consultar saldo: %v", err)
+	}
+	needed := amount.Add(fees.AmountOf(denom))
+	if available := amountOf([]decCoin{balance.Balance}, denom); available.LT(needed) {
+		return nil, fmt.Errorf("saldo insuficiente: %s disponível, envio mais taxa somam %s", sdk.NewCoin(denom, available), sdk.NewCoin(denom, needed))
+	}
+
+	preview := SendPreview{
+		ID:        generateWalletID(),
+		WalletID:  wallet.ID,
+		From:      key.Address(),
+		To:        to,
+		Amount:    amount.String(),
+		Denom:     denom,
+		Memo:      req.Memo,
+		Gas:       gas,
+		Fee:       fees.String(),
+		ExpiresAt: time.Now().Add(sendConfirmTTL).Unix(),
+	}
+	if entry := s.findAddressBookEntry(to); entry != nil {
+		preview.Label = entry.Label
+	}
+
+	s.sendsMutex.Lock()
+	now := time.Now().Unix()
+	for id, p := range s.pendingSends {
+		if p.preview.ExpiresAt < now {
+			delete(s.pendingSends, id)
+		}
+	}
+	s.pendingSends[preview.ID] = &pendingSend{preview: preview, coin: coin, fees: fees}
+	s.sendsMutex.Unlock()
+
+	return &preview, nil
+}
+
+// ConfirmSend signs and broadcasts a send previewed by PrepareSend. Each
+// confirmation can be used once.
+func (s *Service) ConfirmSend(password, confirmationID string) (*tx.Result, error) {
+	s.sendsMutex.Lock()
+	pending, ok := s.pendingSends[confirmationID]
+	s.sendsMutex.Unlock()
+	if !ok || pending.preview.ExpiresAt < time.Now().Unix() {
+		return nil, ErrSendNotFound
+	}
+	preview := pending.preview
+
+	// A wrong password leaves the confirmation in place for another try
+	key, _, err := s.unlockKey(preview.WalletID, password)
+	if err != nil {
+		return nil, err
+	}
+	if key.Address() != preview.From {
+		return nil, errors.New("a carteira não corresponde à confirmação")
+	}
+
+	// Claim the confirmation, so a concurrent request cannot send it twice
+	s.sendsMutex.Lock()
+	_, ok = s.pendingSends[confirmationID]
+	delete(s.pendingSends, confirmationID)
+	s.sendsMutex.Unlock()
+	if !ok {
+		return nil, ErrSendNotFound
+	}
+
+	msg := &banktypes.MsgSend{FromAddress: key.Address(), ToAddress: preview.To, Amount: sdk.NewCoins(pending.coin)}
+	result, err := s.sendTxWith(key, []sdk.Msg{msg}, tx.Options{
+		Memo:     preview.Memo,
+		GasLimit: preview.Gas,
+		Fees:     pending.fees,
+	})
+	if err != nil {
+		return result, fmt.Errorf("erro ao enviar: %v", err)
+	}
+
+	s.addLog(fmt.Sprintf("Sent %s from %s to %s (tx %s)", pending.coin, preview.From, preview.To, result.TxHash))
+	return result, nil
+}
+
+// =============================================================================
+// ADDRESS BOOK
+// =============================================================================
+
+type AddressBookEntry struct {
+	ID        string `json:"id"`
+	Label     string `json:"label"`
+	Address   string `json:"address"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+func (s *Service) GetAddressBook() []AddressBookEntry {
+	entries := []AddressBookEntry{}
+	if data, err := os.ReadFile(filepath.Join(s.dataDir, "addressbook.json")); err == nil {
+		json.Unmarshal(data, &entries)
+	}
+	return entries
+}
+
+func (s *Service) saveAddressBook(entries []AddressBookEntry) error {
+	data, _ := json.MarshalIndent(entries, "", "  ")
+	return os.WriteFile(filepath.Join(s.dataDir, "addressbook.json"), data, 0600)
+}
+
+func (s *Service) findAddressBookEntry(address string) *AddressBookEntry {
+	for _, e := range s.GetAddressBook() {
+		if e.Address == address {
+			return &e
+		}
+	}
+	return nil
+}
+
+// SaveAddressBookEntry adds an entry, or updates the one with the same ID.
+func (s *Service) SaveAddressBookEntry(entry AddressBookEntry) (*AddressBookEntry, error) {
+	entry.Label = strings.TrimSpace(entry.Label)
+	entry.Address = strings.TrimSpace(entry.Address)
+	if entry.Label == "" {
+		return nil, errors.New("informe um nome para o endereço")
+	}
+	if err := tx.ValidateAddress(entry.Address, tx.AccountPrefix); err != nil {
+		return nil, err
+	}
+
+	s.addressBookMutex.Lock()
+	defer s.addressBookMutex.Unlock()
+	entries := s.GetAddressBook()
+	index := -1
+	for i, e := range entries {
+		if e.ID == entry.ID && entry.ID != "" {
+			index = i
+		} else if e.Address == entry.Address {
+			return nil, fmt.Errorf("endereço já cadastrado como %q", e.Label)
+		}
+	}
+
+	if index >= 0 {
+		entry.CreatedAt = entries[index].CreatedAt
+		entries[index] = entry
+	} else {
+		entry.ID = generateWalletID()
+		entry.CreatedAt = time.Now().Unix()
+		entries = append(entries, entry)
+	}
+	if err := s.saveAddressBook(entries); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (s *Service) DeleteAddressBookEntry(id string) error {
+	s.addressBookMutex.Lock()
+	defer s.addressBookMutex.Unlock()
+	entries := s.GetAddressBook()
+	for i, e := range entries {
+		if e.ID == id {
+			return s.saveAddressBook(append(entries[:i], entries[i+1:]...))
+		}
+	}
+	return errors.New("endereço não encontrado")
+}
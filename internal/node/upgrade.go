@@ -0,0 +1,375 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// UPGRADES
+// =============================================================================
+
+const (
+	upgradeWatchInterval = 5 * time.Minute
+	// defaultBlockTime is used for the ETA when past blocks are pruned.
+	defaultBlockTime = 6 * time.Second
+	blockTimeSample  = 1000
+)
+
+// UpgradePlan is the software upgrade scheduled by governance.
+type UpgradePlan struct {
+	Name   string `json:"name"`
+	Height int64  `json:"height,string"`
+	Info   string `json:"info"`
+}
+
+// UpgradeState is kept in upgrade-state.json: the last plan seen and how
+// far its binary got prepared.
+type UpgradeState struct {
+	Name       string `json:"name"`
+	Height     int64  `json:"height"`
+	BinaryURL  string `json:"binaryUrl,omitempty"`
+	Checksum   string `json:"checksum,omitempty"`
+	Path       string `json:"path,omitempty"`
+	Version    string `json:"version,omitempty"`
+	Prepared   bool   `json:"prepared"`
+	PreparedAt int64  `json:"preparedAt,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+type UpgradeStatus struct {
+	Plan          *UpgradePlan  `json:"plan"`
+	CurrentHeight int64         `json:"currentHeight"`
+	BlocksLeft    int64         `json:"blocksLeft"`
+	BlockTime     float64       `json:"blockTime"` // seconds
+	ETA           int64         `json:"eta,omitempty"`
+	State         *UpgradeState `json:"state,omitempty"`
+}
+
+func (s *Service) loadUpgradeState() *UpgradeState {
+	var state UpgradeState
+	data, err := os.ReadFile(filepath.Join(s.dataDir, "upgrade-state.json"))
+	if err != nil || json.Unmarshal(data, &state) != nil {
+		return nil
+	}
+	return &state
+}
+
+func (s *Service) saveUpgradeState(state *UpgradeState) {
+	data, _ := json.MarshalIndent(state, "", "  ")
+	os.WriteFile(filepath.Join(s.dataDir, "upgrade-state.json"), data, 0600)
+}
+
+// getUpgradePlan returns the pending upgrade plan, or nil if none.
+func (s *Service) getUpgradePlan() (*UpgradePlan, error) {
+	var resp struct {
+		Plan *UpgradePlan `json:"plan"`
+	}
+	if err := s.queryChain("/cosmos/upgrade/v1beta1/current_plan", &resp); err != nil {
+		return nil, fmt.Errorf("erro ao consultar plano de upgrade: %v", err)
+	}
+	return resp.Plan, nil
+}
+
+// blockHeader returns the height and time of a block, or the latest one
+// when height is 0.
+func (s *Service) blockHeader(height int64) (int64, time.Time, error) {
+	path := "/cosmos/base/tendermint/v1beta1/blocks/latest"
+	if height > 0 {
+		path = fmt.Sprintf("/cosmos/base/tendermint/v1beta1/blocks/%d", height)
+	}
+	var resp struct {
+		Block struct {
+			Header struct {
+				Height int64     `json:"height,string"`
+				Time   time.Time `json:"time"`
+			} `json:"header"`
+		} `json:"block"`
+	}
+	if err := s.queryChain(path, &resp); err != nil {
+		return 0, time.Time{}, err
+	}
+	return resp.Block.Header.Height, resp.Block.Header.Time, nil
+}
+
+// averageBlockTime measures the block time over the last blockTimeSample
+// blocks.
+func (s *Service) averageBlockTime(latest int64, latestTime time.Time) time.Duration {
+	from := latest - blockTimeSample
+	if from < 1 {
+		from = 1
+	}
+	if from == latest {
+		return defaultBlockTime
+	}
+	_, fromTime, err := s.blockHeader(from)
+	if err != nil || !latestTime.After(fromTime) {
+		return defaultBlockTime
+	}
+	return latestTime.Sub(fromTime) / time.Duration(latest-from)
+}
+
+// GetUpgradeStatus reports the pending upgrade plan with an ETA for its
+// height, and whether its binary is ready for Cosmovisor.
+func (s *Service) GetUpgradeStatus() (*UpgradeStatus, error) {
+	plan, err := s.getUpgradePlan()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &UpgradeStatus{Plan: plan}
+	if state := s.loadUpgradeState(); state != nil && plan != nil && state.Name == plan.Name {
+		status.State = state
+	}
+
+	height, latestTime, err := s.blockHeader(0)
+	if err != nil {
+		return status, nil
+	}
+	status.CurrentHeight = height
+	blockTime := s.averageBlockTime(height, latestTime)
+	status.BlockTime = blockTime.Seconds()
+
+	if plan != nil && plan.Height > height {
+		status.BlocksLeft = plan.Height - height
+		status.ETA = latestTime.Add(time.Duration(status.BlocksLeft) * blockTime).Unix()
+	}
+	return status, nil
+}
+
+// planBinary finds the binary for this host in the plan info, which is the
+// JSON Cosmovisor understands ({"binaries": {"linux/amd64": "url"}}) either
+// inline or behind a URL.
+func planBinary(ctx context.Context, info string) (string, error) {
+	info = strings.TrimSpace(info)
+	if info == "" {
+		return "", errors.New("o plano de upgrade não informa binários; instale manualmente")
+	}
+
+	if strings.HasPrefix(info, "http://") || strings.HasPrefix(info, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, info, nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("erro ao baixar informações do upgrade: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("erro ao baixar informações do upgrade (status %d)", resp.StatusCode)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err != nil {
+			return "", err
+		}
+		info = string(data)
+	}
+
+	var parsed struct {
+		Binaries map[string]string `json:"binaries"`
+	}
+	if err := json.Unmarshal([]byte(info), &parsed); err != nil {
+		return "", fmt.Errorf("informações do upgrade inválidas: %v", err)
+	}
+	return platformBinary(parsed.Binaries)
+}
+
+// platformBinary picks the entry for this host, keyed "os/arch", from a
+// Cosmovisor style binaries map.
+func platformBinary(binaries map[string]string) (string, error) {
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	if u, ok := binaries[platform]; ok {
+		return u, nil
+	}
+	if u, ok := binaries["any"]; ok {
+		return u, nil
+	}
+	return "", fmt.Errorf("não há binário para %s", platform)
+}
+
+// findBinary looks for the daemon binary under dir, as archives may keep it
+// in a bin/ or versioned sub directory.
+func findBinary(dir, name string) (string, error) {
+	var found string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && d.Name() == name {
+			found = path
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if found == "" {
+		return "", fmt.Errorf("%s não encontrado no arquivo baixado", name)
+	}
+	return found, nil
+}
+
+// dryRunBinary runs `<binary> version` to check that it executes on this
+// host, and returns the version it reports.
+func dryRunBinary(ctx context.Context, path string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, path, "version").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("o binário não executa neste host: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// PrepareUpgrade downloads the binary of the pending upgrade plan, verifies
+// its checksum and installs it as cosmovisor/upgrades/<name>/bin, so
+// Cosmovisor switches to it at the upgrade height without downloading
+// anything itself.
+func (s *Service) PrepareUpgrade(ctx context.Context, job *Job) error {
+	plan, err := s.getUpgradePlan()
+	if err != nil {
+		return err
+	}
+	if plan == nil {
+		return errors.New("nenhum upgrade agendado")
+	}
+
+	state := &UpgradeState{Name: plan.Name, Height: plan.Height}
+	err = s.prepareUpgrade(ctx, job, plan, state)
+	if err != nil {
+		state.Error = err.Error()
+		s.logStep(job, fmt.Sprintf("Upgrade %s preparation failed: %v", plan.Name, err))
+	}
+	s.saveUpgradeState(state)
+	return err
+}
+
+func (s *Service) prepareUpgrade(ctx context.Context, job *Job, plan *UpgradePlan, state *UpgradeState) error {
+	s.logStep(job, fmt.Sprintf("Preparing upgrade %s at height %d", plan.Name, plan.Height))
+
+	binaryURL, err := planBinary(ctx, plan.Info)
+	if err != nil {
+		return err
+	}
+	downloadURL, checksum, err := splitChecksum(binaryURL)
+	if err != nil {
+		return err
+	}
+	state.BinaryURL = downloadURL
+	state.Checksum = checksum
+
+	upgradeDir, err := s.upgradeDir(plan.Name)
+	if err != nil {
+		return err
+	}
+	target := filepath.Join(upgradeDir, "bin", filepath.Base(s.getBinaryPath()))
+	version, err := s.installBinary(ctx, job, downloadURL, checksum, target)
+	if err != nil {
+		return err
+	}
+
+	state.Path = target
+	state.Version = version
+	state.Prepared = true
+	state.PreparedAt = time.Now().Unix()
+	s.logStep(job, fmt.Sprintf("Upgrade %s ready at %s", plan.Name, target))
+	return nil
+}
+
+// upgradeDir returns the directory Cosmovisor looks in for the binary of
+// the named upgrade. The name comes from a governance proposal, so one that
+// could leave the upgrades dir is refused.
+func (s *Service) upgradeDir(name string) (string, error) {
+	if name == "" || name == "." || strings.Contains(name, "..") || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("nome de upgrade inválido: %q", name)
+	}
+	return filepath.Join(s.getNodeHome(), "cosmovisor", "upgrades", url.PathEscape(name)), nil
+}
+
+// installBinary downloads a release from downloadURL, verifies it with
+// verifyDownload, extracts the daemon if it comes in a tarball and, once a
+// dry run passes, moves it to target. It returns the version the binary
+// reports.
+func (s *Service) installBinary(ctx context.Context, job *Job, downloadURL, checksum, target string) (string, error) {
+	workDir, err := os.MkdirTemp(s.dataDir, "download-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(workDir)
+
+	u, _ := url.Parse(downloadURL)
+	download := filepath.Join(workDir, filepath.Base(u.Path))
+	s.logStep(job, fmt.Sprintf("Downloading from: %s", downloadURL))
+	if err := s.downloadFile(ctx, downloadURL, download, job); err != nil {
+		return "", err
+	}
+
+	if err := s.verifyDownload(ctx, job, downloadURL, download, checksum); err != nil {
+		return "", err
+	}
+
+	binary := download
+	if strings.HasSuffix(download, ".tar.gz") || strings.HasSuffix(download, ".tgz") {
+		s.logStep(job, "Extracting...")
+		extractDir := filepath.Join(workDir, "extract")
+		os.MkdirAll(extractDir, 0755)
+		cmd := exec.CommandContext(ctx, "tar", "-xzf", download, "-C", extractDir)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return "", fmt.Errorf("erro ao extrair: %s", string(output))
+		}
+		if binary, err = findBinary(extractDir, filepath.Base(target)); err != nil {
+			return "", err
+		}
+	}
+	os.Chmod(binary, 0755)
+
+	version, err := dryRunBinary(ctx, binary)
+	if err != nil {
+		return "", err
+	}
+	s.logStep(job, fmt.Sprintf("Dry run ok, version %s", version))
+
+	os.MkdirAll(filepath.Dir(target), 0755)
+	data, err := os.ReadFile(binary)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(target+".part", data, 0755); err != nil {
+		return "", fmt.Errorf("erro ao instalar binário: %v", err)
+	}
+	if err := os.Rename(target+".part", target); err != nil {
+		return "", fmt.Errorf("erro ao instalar binário: %v", err)
+	}
+	return version, nil
+}
+
+// runUpgradeWatcher prepares the binary of a new upgrade plan as soon as it
+// shows up on chain, when the node runs under Cosmovisor.
+func (s *Service) runUpgradeWatcher() {
+	for range time.Tick(upgradeWatchInterval) {
+		if !s.IsCosmovisorEnabled() || !s.isNodeRunning() {
+			continue
+		}
+		plan, err := s.getUpgradePlan()
+		if err != nil || plan == nil {
+			continue
+		}
+		// A failed preparation is left for the user to retry
+		if state := s.loadUpgradeState(); state != nil && state.Name == plan.Name {
+			continue
+		}
+
+		s.addLog(fmt.Sprintf("Upgrade %s scheduled at height %d", plan.Name, plan.Height))
+		if _, err := s.StartJob(JobPrepareUpgrade); err != nil {
+			s.addLog(fmt.Sprintf("Upgrade preparation not started: %v", err))
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package node
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestUpgradeDir(t *testing.T) {
+	s := newService(t.TempDir(), &fakeRunner{})
+	upgrades := filepath.Join(s.getNodeHome(), "cosmovisor", "upgrades")
+
+	tests := []struct {
+		name string
+		want string // "" when the name is refused
+	}{
+		{name: "v2.0.0", want: "v2.0.0"},
+		{name: "v2 upgrade", want: "v2%20upgrade"},
+		{name: "v2?rc", want: "v2%3Frc"},
+		{name: ""},
+		{name: "."},
+		{name: ".."},
+		{name: "../../.."},
+		{name: "v2/bin"},
+		{name: `v2\bin`},
+		{name: "v2..1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := s.upgradeDir(tt.name)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("upgradeDir(%q) = %s, want an error", tt.name, dir)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("upgradeDir(%q): %v", tt.name, err)
+			}
+			if want := filepath.Join(upgrades, tt.want); dir != want {
+				t.Errorf("upgradeDir(%q) = %s, want %s", tt.name, dir, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,800 @@
+package node
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	sdkmath "cosmossdk.io/math"
+	cryptotypes "github.com/cosmos/cosmos-sdk/crypto/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/bech32"
+	slashingtypes "github.com/cosmos/cosmos-sdk/x/slashing/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/tickfy/tickfy-validator-setup/internal/tx"
+)
+
+// =============================================================================
+// VALIDATOR STATUS
+// =============================================================================
+
+var ErrValidatorNotFound = errors.New("validador não encontrado")
+
+// ValidatorStatus is our validator as the staking and slashing modules see
+// it. Stake and CreatedAt come from validator.json, written when the tool
+// created the validator.
+type ValidatorStatus struct {
+	Moniker          string          `json:"moniker"`
+	OperatorAddress  string          `json:"operatorAddress"`
+	ConsensusAddress string          `json:"consensusAddress"`
+	Status           string          `json:"status"`
+	Jailed           bool            `json:"jailed"`
+	JailedUntil      string          `json:"jailedUntil,omitempty"`
+	Tombstoned       bool            `json:"tombstoned"`
+	MissedBlocks     int64           `json:"missedBlocks"`
+	Tokens           string          `json:"tokens"`
+	VotingPower      int64           `json:"votingPower"`
+	Rank             int             `json:"rank"`
+	ActiveSetSize    int             `json:"activeSetSize"`
+	Commission       string          `json:"commission"`
+	CommissionRates  CommissionRates `json:"commissionRates"`
+	Stake            string          `json:"stake,omitempty"`
+	CreatedAt        int64           `json:"createdAt,omitempty"`
+}
+
+type CommissionRates struct {
+	Rate          string `json:"rate"`
+	MaxRate       string `json:"maxRate"`
+	MaxChangeRate string `json:"maxChangeRate"`
+	UpdateTime    string `json:"updateTime"`
+}
+
+// chainValidator is a validator as /cosmos/staking/v1beta1/validators
+// returns it.
+type chainValidator struct {
+	OperatorAddress   string          `json:"operator_address"`
+	ConsensusPubkey   json.RawMessage `json:"consensus_pubkey"`
+	Jailed            bool            `json:"jailed"`
+	Status            string          `json:"status"`
+	Tokens            string          `json:"tokens"`
+	DelegatorShares   string          `json:"delegator_shares"`
+	MinSelfDelegation string          `json:"min_self_delegation"`
+	Description       struct {
+		Moniker         string `json:"moniker"`
+		Identity        string `json:"identity"`
+		Website         string `json:"website"`
+		SecurityContact string `json:"security_contact"`
+		Details         string `json:"details"`
+	} `json:"description"`
+	Commission struct {
+		CommissionRates struct {
+			Rate          string `json:"rate"`
+			MaxRate       string `json:"max_rate"`
+			MaxChangeRate string `json:"max_change_rate"`
+		} `json:"commission_rates"`
+		UpdateTime string `json:"update_time"`
+	} `json:"commission"`
+}
+
+type signingInfo struct {
+	Address             string `json:"address"`
+	JailedUntil         string `json:"jailed_until"`
+	Tombstoned          bool   `json:"tombstoned"`
+	MissedBlocksCounter string `json:"missed_blocks_counter"`
+}
+
+var bondStatuses = map[string]string{
+	"BOND_STATUS_BONDED":    "bonded",
+	"BOND_STATUS_UNBONDING": "unbonding",
+	"BOND_STATUS_UNBONDED":  "unbonded",
+}
+
+func (s *Service) loadValidatorInfo() (*ValidatorInfo, error) {
+	data, err := os.ReadFile(filepath.Join(s.dataDir, "validator.json"))
+	if err != nil {
+		return nil, err
+	}
+	var valInfo ValidatorInfo
+	if err := json.Unmarshal(data, &valInfo); err != nil {
+		return nil, err
+	}
+	return &valInfo, nil
+}
+
+func (s *Service) saveValidatorInfo(valInfo *ValidatorInfo) error {
+	data, _ := json.MarshalIndent(valInfo, "", "  ")
+	return os.WriteFile(filepath.Join(s.dataDir, "validator.json"), data, 0600)
+}
+
+// queryValidator returns our validator from the staking module, or
+// ErrValidatorNotFound if it was not created yet.
+func (s *Service) queryValidator(valoper string) (*chainValidator, error) {
+	var resp struct {
+		Validator chainValidator `json:"validator"`
+	}
+	if err := s.queryChain("/cosmos/staking/v1beta1/validators/"+valoper, &resp); err != nil {
+		if errors.Is(err, tx.ErrNotFound) {
+			return nil, ErrValidatorNotFound
+		}
+		return nil, fmt.Errorf("erro ao consultar validador: %v", err)
+	}
+	return &resp.Validator, nil
+}
+
+// querySigningInfo returns the slashing signing info of a consensus address.
+// A validator that has not signed a block yet has none.
+func (s *Service) querySigningInfo(consAddress string) (*signingInfo, error) {
+	var resp struct {
+		ValSigningInfo signingInfo `json:"val_signing_info"`
+	}
+	if err := s.queryChain("/cosmos/slashing/v1beta1/signing_infos/"+consAddress, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.ValSigningInfo, nil
+}
+
+// consensusAddress returns the tickfyvalcons1... address of our node's
+// consensus key. When the node key cannot be read the key registered on
+// chain is used instead.
+func (s *Service) consensusAddress(val *chainValidator) string {
+	pubKey, err := s.getConsensusPubKey()
+	if err != nil && val != nil && len(val.ConsensusPubkey) > 0 {
+		var chainKey cryptotypes.PubKey
+		if tx.Codec().UnmarshalInterfaceJSON(val.ConsensusPubkey, &chainKey) == nil {
+			pubKey, err = chainKey, nil
+		}
+	}
+	if err != nil {
+		return ""
+	}
+	addr, _ := bech32.ConvertAndEncode(tx.ConsensusPrefix, pubKey.Address())
+	return addr
+}
+
+// activeSetRank returns the 1-based position of valoper among the bonded
+// validators by tokens, 0 if it is not in the active set, and the size of
+// the set.
+func (s *Service) activeSetRank(valoper string) (int, int, error) {
+	var resp struct {
+		Validators []chainValidator `json:"validators"`
+	}
+	if err := s.queryChain("/cosmos/staking/v1beta1/validators?status=BOND_STATUS_BONDED&pagination.limit=1000", &resp); err != nil {
+		return 0, 0, fmt.Errorf("erro ao consultar validadores ativos: %v", err)
+	}
+
+	tokens := make(map[string]sdkmath.Int, len(resp.Validators))
+	for _, v := range resp.Validators {
+		amount, ok := sdkmath.NewIntFromString(v.Tokens)
+		if !ok {
+			amount = sdkmath.ZeroInt()
+		}
+		tokens[v.OperatorAddress] = amount
+	}
+	sort.SliceStable(resp.Validators, func(i, j int) bool {
+		return tokens[resp.Validators[i].OperatorAddress].GT(tokens[resp.Validators[j].OperatorAddress])
+	})
+
+	for i, v := range resp.Validators {
+		if v.OperatorAddress == valoper {
+			return i + 1, len(resp.Validators), nil
+		}
+	}
+	return 0, len(resp.Validators), nil
+}
+
+// GetValidatorStatus resolves our validator from the active wallet and
+// reports its bond status, jailing and position in the active set.
+func (s *Service) GetValidatorStatus() (*ValidatorStatus, error) {
+	_, valoper, err := s.getOperatorAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := s.queryValidator(valoper)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, ok := sdkmath.NewIntFromString(val.Tokens)
+	if !ok {
+		tokens = sdkmath.ZeroInt()
+	}
+
+	status := &ValidatorStatus{
+		Moniker:         val.Description.Moniker,
+		OperatorAddress: val.OperatorAddress,
+		Status:          bondStatuses[val.Status],
+		Jailed:          val.Jailed,
+		Tokens:          tokens.String(),
+		VotingPower:     sdk.TokensToConsensusPower(tokens, sdk.DefaultPowerReduction),
+		Commission:      val.Commission.CommissionRates.Rate,
+		CommissionRates: CommissionRates{
+			Rate:          val.Commission.CommissionRates.Rate,
+			MaxRate:       val.Commission.CommissionRates.MaxRate,
+			MaxChangeRate: val.Commission.CommissionRates.MaxChangeRate,
+			UpdateTime:    val.Commission.UpdateTime,
+		},
+	}
+	if status.Status == "" {
+		status.Status = strings.ToLower(strings.TrimPrefix(val.Status, "BOND_STATUS_"))
+	}
+
+	if valInfo, err := s.loadValidatorInfo(); err == nil {
+		status.Stake = valInfo.Stake
+		status.CreatedAt = valInfo.CreatedAt
+	}
+
+	status.ConsensusAddress = s.consensusAddress(val)
+	if status.ConsensusAddress != "" {
+		info, err := s.querySigningInfo(status.ConsensusAddress)
+		if err != nil && !errors.Is(err, tx.ErrNotFound) {
+			return nil, fmt.Errorf("erro ao consultar signing info: %v", err)
+		}
+		if info != nil {
+			status.Tombstoned = info.Tombstoned
+			fmt.Sscanf(info.MissedBlocksCounter, "%d", &status.MissedBlocks)
+			if until, err := time.Parse(time.RFC3339Nano, info.JailedUntil); err == nil && until.Unix() > 0 {
+				status.JailedUntil = info.JailedUntil
+			}
+		}
+	}
+
+	status.Rank, status.ActiveSetSize, err = s.activeSetRank(valoper)
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// =============================================================================
+// UNJAIL
+// =============================================================================
+
+// ErrCannotUnjail wraps every precondition of Unjail that is not met.
+var ErrCannotUnjail = errors.New("unjail não é possível")
+
+const (
+	unjailPollInterval = 3 * time.Second
+	unjailPollTimeout  = 90 * time.Second
+)
+
+type UnjailResult struct {
+	Tx     *tx.Result       `json:"tx"`
+	Bonded bool             `json:"bonded"`
+	Status *ValidatorStatus `json:"status,omitempty"`
+}
+
+// rpcStatus is the part of the CometBFT /status response Unjail checks.
+type rpcStatus struct {
+	SyncInfo struct {
+		LatestBlockHeight string `json:"latest_block_height"`
+		CatchingUp        bool   `json:"catching_up"`
+	} `json:"sync_info"`
+	ValidatorInfo struct {
+		Address string `json:"address"`
+	} `json:"validator_info"`
+}
+
+func (s *Service) getRPCStatus() (*rpcStatus, error) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("http://localhost:26657/status")
+	if err != nil {
+		return nil, errRPCUnreachable
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Result rpcStatus `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, errRPCUnreachable
+	}
+	return &result.Result, nil
+}
+
+// checkUnjail returns why the validator cannot be unjailed yet, or nil.
+func (s *Service) checkUnjail(address string, val *chainValidator) error {
+	if !val.Jailed {
+		return fmt.Errorf("%w: o validador não está jailed", ErrCannotUnjail)
+	}
+
+	var chainKey cryptotypes.PubKey
+	if err := tx.Codec().UnmarshalInterfaceJSON(val.ConsensusPubkey, &chainKey); err != nil {
+		return fmt.Errorf("chave de consenso do validador inválida: %v", err)
+	}
+	consAddress, _ := bech32.ConvertAndEncode(tx.ConsensusPrefix, chainKey.Address())
+
+	info, err := s.querySigningInfo(consAddress)
+	if err != nil {
+		return fmt.Errorf("erro ao consultar signing info: %v", err)
+	}
+	if info.Tombstoned {
+		return fmt.Errorf("%w: o validador foi tombstoned (double sign) e não pode mais sair da jail; é preciso criar um novo validador com outra chave de consenso", ErrCannotUnjail)
+	}
+	if until, err := time.Parse(time.RFC3339Nano, info.JailedUntil); err == nil && time.Now().Before(until) {
+		return fmt.Errorf("%w: o validador está em jail até %s (faltam %s)", ErrCannotUnjail,
+			until.Local().Format("02/01/2006 15:04:05"), time.Until(until).Round(time.Second))
+	}
+
+	minSelf, ok := sdkmath.NewIntFromString(val.MinSelfDelegation)
+	if ok && minSelf.IsPositive() {
+		var selfResp struct {
+			DelegationResponse struct {
+				Balance decCoin `json:"balance"`
+			} `json:"delegation_response"`
+		}
+		selfBonded := sdkmath.ZeroInt()
+		err := s.queryChain(fmt.Sprintf("/cosmos/staking/v1beta1/validators/%s/delegations/%s", val.OperatorAddress, address), &selfResp)
+		if err == nil {
+			selfBonded = amountOf([]decCoin{selfResp.DelegationResponse.Balance}, selfResp.DelegationResponse.Balance.Denom)
+		} else if !errors.Is(err, tx.ErrNotFound) {
+			return fmt.Errorf("erro ao consultar autodelegação: %v", err)
+		}
+		if selfBonded.LT(minSelf) {
+			return fmt.Errorf("%w: a autodelegação (%s) está abaixo do mínimo do validador (%s); delegue mais antes do unjail", ErrCannotUnjail, selfBonded, minSelf)
+		}
+	}
+
+	if !s.isNodeRunning() {
+		return fmt.Errorf("%w: o node não está rodando; inicie o node para que ele volte a assinar blocos", ErrCannotUnjail)
+	}
+	rpc, err := s.getRPCStatus()
+	if err != nil {
+		return fmt.Errorf("%w: o RPC do node não respondeu", ErrCannotUnjail)
+	}
+	if rpc.SyncInfo.CatchingUp {
+		return fmt.Errorf("%w: o node ainda está sincronizando (bloco %s); aguarde a sincronização terminar para não ser jailed de novo", ErrCannotUnjail, rpc.SyncInfo.LatestBlockHeight)
+	}
+	if !strings.EqualFold(rpc.ValidatorInfo.Address, hex.EncodeToString(chainKey.Address())) {
+		return fmt.Errorf("%w: o node está usando outra chave de consenso (%s) que a registrada no validador; ele não assinaria blocos. Restaure o priv_validator_key.json do validador", ErrCannotUnjail, rpc.ValidatorInfo.Address)
+	}
+	return nil
+}
+
+// Unjail checks that the validator can be unjailed and that our node is
+// synced with the validator's consensus key, sends MsgUnjail and waits for
+// the validator to be bonded again.
+func (s *Service) Unjail(password, walletID string) (*UnjailResult, error) {
+	key, _, err := s.unlockKey(walletID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	valoper := key.ValidatorAddress()
+	val, err := s.queryValidator(valoper)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkUnjail(key.Address(), val); err != nil {
+		return nil, err
+	}
+
+	result, err := s.sendTx(key, []sdk.Msg{slashingtypes.NewMsgUnjail(valoper)}, "unjail")
+	if err != nil {
+		return nil, fmt.Errorf("erro no unjail: %v", err)
+	}
+	s.addLog(fmt.Sprintf("Unjail sent for %s (tx %s)", valoper, result.TxHash))
+
+	// The validator only returns to the active set at the end of a block
+	unjailed := &UnjailResult{Tx: result}
+	deadline := time.Now().Add(unjailPollTimeout)
+	for time.Now().Before(deadline) {
+		if val, err := s.queryValidator(valoper); err == nil && !val.Jailed && val.Status == "BOND_STATUS_BONDED" {
+			unjailed.Bonded = true
+			break
+		}
+		time.Sleep(unjailPollInterval)
+	}
+
+	if unjailed.Bonded {
+		s.addLog(fmt.Sprintf("Validator %s is bonded again", valoper))
+	} else {
+		s.addLog(fmt.Sprintf("Validator %s unjailed but not bonded after %s", valoper, unjailPollTimeout))
+	}
+	if status, err := s.GetValidatorStatus(); err == nil && status.OperatorAddress == valoper {
+		unjailed.Status = status
+	}
+	return unjailed, nil
+}
+
+// =============================================================================
+// EDIT VALIDATOR
+// =============================================================================
+
+// ValidatorEdit lists the changes to make; nil fields are left as they are.
+type ValidatorEdit struct {
+	Moniker         *string `json:"moniker"`
+	Identity        *string `json:"identity"`
+	Website         *string `json:"website"`
+	SecurityContact *string `json:"securityContact"`
+	Details         *string `json:"details"`
+	CommissionRate  string  `json:"commissionRate"`
+}
+
+// commissionChangeInterval is how often the staking module lets a validator
+// change its commission rate.
+const commissionChangeInterval = 24 * time.Hour
+
+// checkCommissionChange validates a new rate the way the staking module
+// would, so the user gets the reason before paying for a failed tx.
+func (s *Service) checkCommissionChange(val *chainValidator, newRate sdkmath.LegacyDec) error {
+	rates := val.Commission.CommissionRates
+	current, err := sdkmath.LegacyNewDecFromStr(rates.Rate)
+	if err != nil {
+		return fmt.Errorf("comissão atual inválida: %v", err)
+	}
+	maxRate, err := sdkmath.LegacyNewDecFromStr(rates.MaxRate)
+	if err != nil {
+		return fmt.Errorf("comissão máxima inválida: %v", err)
+	}
+	maxChange, err := sdkmath.LegacyNewDecFromStr(rates.MaxChangeRate)
+	if err != nil {
+		return fmt.Errorf("variação máxima de comissão inválida: %v", err)
+	}
+
+	if newRate.IsNegative() {
+		return errors.New("a comissão não pode ser negativa")
+	}
+	if newRate.GT(maxRate) {
+		return fmt.Errorf("a comissão %s excede a comissão máxima do validador (%s)", newRate, maxRate)
+	}
+	if newRate.Sub(current).Abs().GT(maxChange) {
+		return fmt.Errorf("a comissão só pode variar %s por vez (atual %s, nova %s)", maxChange, current, newRate)
+	}
+
+	var params struct {
+		Params struct {
+			MinCommissionRate string `json:"min_commission_rate"`
+		} `json:"params"`
+	}
+	if err := s.queryChain("/cosmos/staking/v1beta1/params", &params); err != nil {
+		return fmt.Errorf("erro ao consultar parâmetros de staking: %v", err)
+	}
+	if minRate, err := sdkmath.LegacyNewDecFromStr(params.Params.MinCommissionRate); err == nil && newRate.LT(minRate) {
+		return fmt.Errorf("a comissão %s está abaixo da mínima da rede (%s)", newRate, minRate)
+	}
+
+	if updated, err := time.Parse(time.RFC3339Nano, val.Commission.UpdateTime); err == nil {
+		if next := updated.Add(commissionChangeInterval); time.Now().Before(next) {
+			return fmt.Errorf("a comissão foi alterada há menos de 24h; a próxima alteração é possível em %s", next.Local().Format("02/01/2006 15:04:05"))
+		}
+	}
+	return nil
+}
+
+// EditValidator changes the description and commission rate of the
+// validator operated by the wallet and records them in validator.json.
+func (s *Service) EditValidator(password, walletID string, edit ValidatorEdit) (*tx.Result, error) {
+	key, _, err := s.unlockKey(walletID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	valoper := key.ValidatorAddress()
+	val, err := s.queryValidator(valoper)
+	if err != nil {
+		return nil, err
+	}
+
+	field := func(v *string) string {
+		if v == nil {
+			return stakingtypes.DoNotModifyDesc
+		}
+		return strings.TrimSpace(*v)
+	}
+	desc := stakingtypes.NewDescription(field(edit.Moniker), field(edit.Identity), field(edit.Website), field(edit.SecurityContact), field(edit.Details))
+	if desc.Moniker == "" {
+		return nil, errors.New("o moniker não pode ficar vazio")
+	}
+	if _, err := desc.EnsureLength(); err != nil {
+		return nil, fmt.Errorf("descrição inválida: %v", err)
+	}
+
+	var newRate *sdkmath.LegacyDec
+	if edit.CommissionRate != "" {
+		rate, err := sdkmath.LegacyNewDecFromStr(edit.CommissionRate)
+		if err != nil {
+			return nil, errors.New("taxa de comissão inválida")
+		}
+		if current, err := sdkmath.LegacyNewDecFromStr(val.Commission.CommissionRates.Rate); err != nil || !rate.Equal(current) {
+			if err := s.checkCommissionChange(val, rate); err != nil {
+				return nil, err
+			}
+			newRate = &rate
+		}
+	}
+
+	if newRate == nil && desc == stakingtypes.NewDescription(stakingtypes.DoNotModifyDesc, stakingtypes.DoNotModifyDesc,
+		stakingtypes.DoNotModifyDesc, stakingtypes.DoNotModifyDesc, stakingtypes.DoNotModifyDesc) {
+		return nil, errors.New("nenhuma alteração informada")
+	}
+
+	msg := stakingtypes.NewMsgEditValidator(valoper, desc, newRate, nil)
+	result, err := s.sendTx(key, []sdk.Msg{msg}, "")
+	if err != nil {
+		s.addLog(fmt.Sprintf("Edit validator error: %v", err))
+		return result, fmt.Errorf("erro ao editar validador: %v", err)
+	}
+
+	valInfo, err := s.loadValidatorInfo()
+	if err != nil {
+		valInfo = &ValidatorInfo{}
+	}
+	current := val.Description
+	merged := []struct {
+		dst    *string
+		edited *string
+		chain  string
+	}{
+		{&valInfo.Moniker, edit.Moniker, current.Moniker},
+		{&valInfo.Identity, edit.Identity, current.Identity},
+		{&valInfo.Website, edit.Website, current.Website},
+		{&valInfo.SecurityContact, edit.SecurityContact, current.SecurityContact},
+		{&valInfo.Details, edit.Details, current.Details},
+	}
+	for _, m := range merged {
+		if m.edited != nil {
+			*m.dst = strings.TrimSpace(*m.edited)
+		} else {
+			*m.dst = m.chain
+		}
+	}
+	if newRate != nil {
+		valInfo.Commission = edit.CommissionRate
+	} else if valInfo.Commission == "" {
+		valInfo.Commission = val.Commission.CommissionRates.Rate
+	}
+	valInfo.UpdatedAt = time.Now().Unix()
+	s.saveValidatorInfo(valInfo)
+
+	s.addLog(fmt.Sprintf("Validator %s edited (tx %s)", valoper, result.TxHash))
+	return result, nil
+}
+
+// =============================================================================
+// CREATE VALIDATOR
+// =============================================================================
+
+// CreateValidatorRequest holds what the user chooses for a new validator.
+// Rates are decimals ("0.10" is 10%); Amount and MinSelfDelegation are in
+// base units of Denom, which defaults to the bond denom.
+type CreateValidatorRequest struct {
+	Moniker                 string `json:"moniker"`
+	Identity                string `json:"identity"`
+	Website                 string `json:"website"`
+	SecurityContact         string `json:"securityContact"`
+	Details                 string `json:"details"`
+	Amount                  string `json:"stakeAmount"`
+	Denom                   string `json:"denom"`
+	CommissionRate          string `json:"commission"`
+	CommissionMaxRate       string `json:"commissionMaxRate"`
+	CommissionMaxChangeRate string `json:"commissionMaxChangeRate"`
+	MinSelfDelegation       string `json:"minSelfDelegation"`
+}
+
+// ValidatorFile is the JSON file `tx staking create-validator` takes since
+// SDK v0.50. The transaction is signed in process, so it is only built in
+// memory and turned into the message the CLI would send.
+type ValidatorFile struct {
+	PubKey                  json.RawMessage `json:"pubkey"`
+	Amount                  string          `json:"amount"`
+	Moniker                 string          `json:"moniker"`
+	Identity                string          `json:"identity,omitempty"`
+	Website                 string          `json:"website,omitempty"`
+	Security                string          `json:"security,omitempty"`
+	Details                 string          `json:"details,omitempty"`
+	CommissionRate          string          `json:"commission-rate"`
+	CommissionMaxRate       string          `json:"commission-max-rate"`
+	CommissionMaxChangeRate string          `json:"commission-max-change-rate"`
+	MinSelfDelegation       string          `json:"min-self-delegation"`
+}
+
+func parseRate(value, name string) (sdkmath.LegacyDec, error) {
+	if value == "" {
+		return sdkmath.LegacyDec{}, fmt.Errorf("informe %s", name)
+	}
+	rate, err := sdkmath.LegacyNewDecFromStr(value)
+	if err != nil || rate.IsNegative() || rate.GT(sdkmath.LegacyOneDec()) {
+		return sdkmath.LegacyDec{}, fmt.Errorf("%s deve ser um decimal entre 0 e 1", name)
+	}
+	return rate, nil
+}
+
+// buildValidatorFile validates req against the staking params and returns
+// the validator file for address.
+func (s *Service) buildValidatorFile(req CreateValidatorRequest, address string) (*ValidatorFile, error) {
+	desc := stakingtypes.NewDescription(strings.TrimSpace(req.Moniker), req.Identity, req.Website, req.SecurityContact, req.Details)
+	if desc.Moniker == "" {
+		return nil, errors.New("informe o moniker")
+	}
+	if _, err := desc.EnsureLength(); err != nil {
+		return nil, fmt.Errorf("descrição inválida: %v", err)
+	}
+
+	rate, err := parseRate(req.CommissionRate, "a comissão")
+	if err != nil {
+		return nil, err
+	}
+	maxRate, err := parseRate(req.CommissionMaxRate, "a comissão máxima")
+	if err != nil {
+		return nil, err
+	}
+	maxChange, err := parseRate(req.CommissionMaxChangeRate, "a variação máxima de comissão")
+	if err != nil {
+		return nil, err
+	}
+	if rate.GT(maxRate) {
+		return nil, fmt.Errorf("a comissão (%s) não pode exceder a comissão máxima (%s)", req.CommissionRate, req.CommissionMaxRate)
+	}
+	if maxChange.GT(maxRate) {
+		return nil, fmt.Errorf("a variação máxima de comissão (%s) não pode exceder a comissão máxima (%s)", req.CommissionMaxChangeRate, req.CommissionMaxRate)
+	}
+
+	amount, ok := sdkmath.NewIntFromString(req.Amount)
+	if !ok || !amount.IsPositive() {
+		return nil, errors.New("valor de stake inválido")
+	}
+	minSelf := sdkmath.OneInt()
+	if req.MinSelfDelegation != "" {
+		minSelf, ok = sdkmath.NewIntFromString(req.MinSelfDelegation)
+		if !ok || !minSelf.IsPositive() {
+			return nil, errors.New("autodelegação mínima inválida")
+		}
+	}
+	if amount.LT(minSelf) {
+		return nil, fmt.Errorf("o stake (%s) é menor que a autodelegação mínima (%s)", amount, minSelf)
+	}
+
+	var params struct {
+		Params struct {
+			BondDenom         string `json:"bond_denom"`
+			MinCommissionRate string `json:"min_commission_rate"`
+		} `json:"params"`
+	}
+	if err := s.queryChain("/cosmos/staking/v1beta1/params", &params); err != nil {
+		return nil, fmt.Errorf("erro ao consultar parâmetros de staking: %v", err)
+	}
+	denom := req.Denom
+	if denom == "" {
+		denom = params.Params.BondDenom
+	}
+	if denom != params.Params.BondDenom {
+		return nil, fmt.Errorf("o stake deve ser em %s, o denom de staking da rede", params.Params.BondDenom)
+	}
+	if minRate, err := sdkmath.LegacyNewDecFromStr(params.Params.MinCommissionRate); err == nil && rate.LT(minRate) {
+		return nil, fmt.Errorf("a comissão (%s) está abaixo da mínima da rede (%s)", req.CommissionRate, minRate)
+	}
+
+	valoper, err := tx.ValidatorAddressOf(address)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.queryValidator(valoper); err == nil {
+		return nil, fmt.Errorf("a carteira já opera o validador %s", valoper)
+	} else if !errors.Is(err, ErrValidatorNotFound) {
+		return nil, err
+	}
+
+	pubKey := s.getValidatorPubKey()
+	if pubKey == "" {
+		return nil, errors.New("chave de consenso do node não encontrada. Inicialize o node primeiro.")
+	}
+
+	return &ValidatorFile{
+		PubKey:                  json.RawMessage(pubKey),
+		Amount:                  amount.String() + denom,
+		Moniker:                 desc.Moniker,
+		Identity:                desc.Identity,
+		Website:                 desc.Website,
+		Security:                desc.SecurityContact,
+		Details:                 desc.Details,
+		CommissionRate:          rate.String(),
+		CommissionMaxRate:       maxRate.String(),
+		CommissionMaxChangeRate: maxChange.String(),
+		MinSelfDelegation:       minSelf.String(),
+	}, nil
+}
+
+// msg builds the MsgCreateValidator the CLI would build from the file.
+func (f *ValidatorFile) msg(valoper string) (*stakingtypes.MsgCreateValidator, error) {
+	var pubKey cryptotypes.PubKey
+	if err := tx.Codec().UnmarshalInterfaceJSON(f.PubKey, &pubKey); err != nil {
+		return nil, fmt.Errorf("chave de consenso inválida: %v", err)
+	}
+	amount, err := sdk.ParseCoinNormalized(f.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("valor de stake inválido: %v", err)
+	}
+	rate, err := sdkmath.LegacyNewDecFromStr(f.CommissionRate)
+	if err != nil {
+		return nil, err
+	}
+	maxRate, err := sdkmath.LegacyNewDecFromStr(f.CommissionMaxRate)
+	if err != nil {
+		return nil, err
+	}
+	maxChange, err := sdkmath.LegacyNewDecFromStr(f.CommissionMaxChangeRate)
+	if err != nil {
+		return nil, err
+	}
+	minSelf, ok := sdkmath.NewIntFromString(f.MinSelfDelegation)
+	if !ok {
+		return nil, errors.New("autodelegação mínima inválida")
+	}
+
+	return stakingtypes.NewMsgCreateValidator(
+		valoper,
+		pubKey,
+		amount,
+		stakingtypes.NewDescription(f.Moniker, f.Identity, f.Website, f.Security, f.Details),
+		stakingtypes.NewCommissionRates(rate, maxRate, maxChange),
+		minSelf,
+	)
+}
+
+// CreateValidator builds the validator file for the wallet and submits it
+// as MsgCreateValidator, once the balance is known to cover the stake and
+// the fee.
+func (s *Service) CreateValidator(req CreateValidatorRequest, password, walletID string) (*tx.Result, error) {
+	key, _, err := s.unlockKey(walletID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := s.buildValidatorFile(req, key.Address())
+	if err != nil {
+		return nil, err
+	}
+	msg, err := file.msg(key.ValidatorAddress())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	gas, fees, err := s.txClient().Estimate(ctx, key, []sdk.Msg{msg}, tx.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao estimar taxa: %v", err)
+	}
+
+	denom := msg.Value.Denom
+	var balance struct {
+		Balance decCoin `json:"balance"`
+	}
+	if err := s.queryChain(fmt.Sprintf("/cosmos/bank/v1beta1/balances/%s/by_denom?denom=%s", key.Address(), denom), &balance); err != nil {
+		return nil, fmt.Errorf("erro ao consultar saldo: %v", err)
+	}
+	needed := msg.Value.Amount.Add(fees.AmountOf(denom))
+	if available := amountOf([]decCoin{balance.Balance}, denom); available.LT(needed) {
+		return nil, fmt.Errorf("saldo insuficiente: %s disponível, stake mais taxa somam %s", sdk.NewCoin(denom, available), sdk.NewCoin(denom, needed))
+	}
+
+	result, err := s.sendTxWith(key, []sdk.Msg{msg}, tx.Options{GasLimit: gas, Fees: fees})
+	if err != nil {
+		s.addLog(fmt.Sprintf("Create validator error: %v", err))
+		return result, fmt.Errorf("erro ao criar validador: %v", err)
+	}
+
+	s.saveValidatorInfo(&ValidatorInfo{
+		Moniker:         file.Moniker,
+		Identity:        file.Identity,
+		Website:         file.Website,
+		SecurityContact: file.Security,
+		Details:         file.Details,
+		Commission:      req.CommissionRate,
+		Stake:           req.Amount,
+		CreatedAt:       time.Now().Unix(),
+	})
+
+	s.addLog("Validator created successfully")
+	return result, nil
+}
@@ -0,0 +1,337 @@
+package node
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// DOWNLOAD VERIFICATION
+// =============================================================================
+
+// Every release directory is expected to publish a sha256sum style manifest
+// next to its files and, when signed, a detached ed25519 signature of it.
+const (
+	manifestName        = "SHA256SUMS"
+	manifestSigName     = "SHA256SUMS.sig"
+	maxQuarantineRecord = 100
+)
+
+var (
+	ErrChecksumMismatch = errors.New("checksum não confere")
+	ErrBadSignature     = errors.New("assinatura do manifesto inválida")
+	ErrHashNotPinned    = errors.New("hash não configurado")
+)
+
+var sha256Re = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// VerifyConfig is kept in verify.json.
+type VerifyConfig struct {
+	// SigningKey is the base64 ed25519 public key manifests must be signed
+	// with. Without it only the checksums are verified.
+	SigningKey string `json:"signingKey,omitempty"`
+	// GenesisHashes maps a chain ID to the sha256 of its genesis.json. A
+	// genesis is only installed when its chain ID is listed.
+	GenesisHashes map[string]string `json:"genesisHashes,omitempty"`
+	// CosmovisorHashes maps a Cosmovisor release tarball name, such as
+	// cosmovisor-v1.5.0-linux-amd64.tar.gz, to its sha256. Cosmovisor is
+	// only installed when its tarball is listed.
+	CosmovisorHashes map[string]string `json:"cosmovisorHashes,omitempty"`
+}
+
+type QuarantineRecord struct {
+	Time   int64  `json:"time"`
+	Source string `json:"source"`
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+func (s *Service) GetVerifyConfig() VerifyConfig {
+	var cfg VerifyConfig
+	if data, err := os.ReadFile(filepath.Join(s.dataDir, "verify.json")); err == nil {
+		json.Unmarshal(data, &cfg)
+	}
+	return cfg
+}
+
+func (s *Service) SaveVerifyConfig(cfg VerifyConfig) error {
+	cfg.SigningKey = strings.TrimSpace(cfg.SigningKey)
+	if cfg.SigningKey != "" {
+		if _, err := parseSigningKey(cfg.SigningKey); err != nil {
+			return err
+		}
+	}
+	for chainID, h := range cfg.GenesisHashes {
+		h, ok := normalizeHash(h)
+		if !ok {
+			return fmt.Errorf("hash do genesis inválido para %s", chainID)
+		}
+		cfg.GenesisHashes[chainID] = h
+	}
+	for name, h := range cfg.CosmovisorHashes {
+		h, ok := normalizeHash(h)
+		if !ok {
+			return fmt.Errorf("hash do Cosmovisor inválido para %s", name)
+		}
+		cfg.CosmovisorHashes[name] = h
+	}
+
+	data, _ := json.MarshalIndent(cfg, "", "  ")
+	return os.WriteFile(filepath.Join(s.dataDir, "verify.json"), data, 0600)
+}
+
+// normalizeHash accepts a sha256 in hex, with or without a "sha256:" prefix.
+func normalizeHash(h string) (string, bool) {
+	h = strings.TrimPrefix(strings.TrimSpace(h), "sha256:")
+	return strings.ToLower(h), sha256Re.MatchString(h)
+}
+
+func parseSigningKey(key string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, errors.New("chave pública inválida, use uma chave ed25519 em base64")
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// splitChecksum separates the go-getter style ?checksum=sha256:<hex>
+// parameter from a download URL. The checksum is empty when there is none.
+func splitChecksum(downloadURL string) (string, string, error) {
+	u, err := url.Parse(downloadURL)
+	if err != nil {
+		return "", "", fmt.Errorf("URL do binário inválida: %v", err)
+	}
+	q := u.Query()
+	checksum := q.Get("checksum")
+	q.Del("checksum")
+	u.RawQuery = q.Encode()
+	return u.String(), checksum, nil
+}
+
+// verifyChecksum checks the file against "sha256:<hex>" or "sha512:<hex>".
+func verifyChecksum(path, checksum string) error {
+	algo, expected, ok := strings.Cut(checksum, ":")
+	if !ok {
+		return fmt.Errorf("checksum inválido: %s", checksum)
+	}
+
+	var h hash.Hash
+	switch strings.ToLower(algo) {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("tipo de checksum não suportado: %s", algo)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, expected) {
+		return fmt.Errorf("%w: esperado %s, obtido %s", ErrChecksumMismatch, expected, got)
+	}
+	return nil
+}
+
+func fetchURL(ctx context.Context, fileURL string, limit int64) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
+
+// siblingURL replaces the file name of fileURL. It works on the raw string
+// so escaped paths such as GitHub's cosmovisor%2Fv1.5.0 are kept as is.
+func siblingURL(fileURL, name string) string {
+	fileURL, _, _ = strings.Cut(fileURL, "?")
+	return fileURL[:strings.LastIndex(fileURL, "/")+1] + name
+}
+
+// parseManifest reads "<hex>  <name>" lines as written by sha256sum.
+func parseManifest(data []byte) map[string]string {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || !sha256Re.MatchString(fields[0]) {
+			continue
+		}
+		name := strings.TrimPrefix(fields[1], "*")
+		sums[filepath.Base(name)] = strings.ToLower(fields[0])
+	}
+	return sums
+}
+
+// fetchManifest downloads the manifest published next to fileURL and, when
+// a signing key is pinned, checks its signature.
+func (s *Service) fetchManifest(ctx context.Context, fileURL string) (map[string]string, error) {
+	data, err := fetchURL(ctx, siblingURL(fileURL, manifestName), 1<<20)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao baixar manifesto %s: %v", manifestName, err)
+	}
+
+	if key := s.GetVerifyConfig().SigningKey; key != "" {
+		pub, err := parseSigningKey(key)
+		if err != nil {
+			return nil, err
+		}
+		sig, err := fetchURL(ctx, siblingURL(fileURL, manifestSigName), 4096)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao baixar assinatura %s: %v", manifestSigName, err)
+		}
+		// The signature may be raw or base64
+		if len(sig) != ed25519.SignatureSize {
+			if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig))); err == nil {
+				sig = decoded
+			}
+		}
+		if !ed25519.Verify(pub, data, sig) {
+			return nil, ErrBadSignature
+		}
+	}
+	return parseManifest(data), nil
+}
+
+// verifyDownload checks a file downloaded from fileURL before it is used.
+// A known checksum, from a release index or upgrade plan, is checked
+// directly; without one the file has to be listed in the manifest. With a
+// signing key pinned the signed manifest is always required. A file that
+// fails is moved to quarantine.
+func (s *Service) verifyDownload(ctx context.Context, job *Job, fileURL, path, checksum string) error {
+	err := s.checkDownload(ctx, fileURL, path, checksum)
+	if err == nil {
+		s.logStep(job, fmt.Sprintf("Verified %s", filepath.Base(path)))
+		return nil
+	}
+	if errors.Is(err, ErrChecksumMismatch) || errors.Is(err, ErrBadSignature) {
+		s.quarantine(path, fileURL, err.Error())
+		s.logStep(job, fmt.Sprintf("Verification failed, %s quarantined: %v", filepath.Base(path), err))
+	}
+	return err
+}
+
+func (s *Service) checkDownload(ctx context.Context, fileURL, path, checksum string) error {
+	if checksum != "" {
+		if err := verifyChecksum(path, checksum); err != nil {
+			return err
+		}
+		if s.GetVerifyConfig().SigningKey == "" {
+			return nil
+		}
+	}
+
+	sums, err := s.fetchManifest(ctx, fileURL)
+	if err != nil {
+		if checksum == "" && !errors.Is(err, ErrBadSignature) {
+			return fmt.Errorf("%v; o download não pode ser verificado", err)
+		}
+		return err
+	}
+	base, _, _ := strings.Cut(fileURL, "?")
+	name := base[strings.LastIndex(base, "/")+1:]
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	expected, ok := sums[name]
+	if !ok {
+		return fmt.Errorf("%s não consta no manifesto %s", name, manifestName)
+	}
+	return verifyChecksum(path, "sha256:"+expected)
+}
+
+// quarantine moves a file that failed verification out of the way, so it
+// is kept for inspection but never installed.
+func (s *Service) quarantine(path, source, reason string) {
+	dir := filepath.Join(s.dataDir, "quarantine")
+	os.MkdirAll(dir, 0700)
+	dest := filepath.Join(dir, fmt.Sprintf("%d-%s", time.Now().Unix(), filepath.Base(path)))
+	if err := os.Rename(path, dest); err != nil {
+		os.Remove(path)
+		dest = ""
+	}
+
+	records := append([]QuarantineRecord{{
+		Time:   time.Now().Unix(),
+		Source: source,
+		Path:   dest,
+		Reason: reason,
+	}}, s.GetQuarantine()...)
+	if len(records) > maxQuarantineRecord {
+		records = records[:maxQuarantineRecord]
+	}
+	data, _ := json.MarshalIndent(records, "", "  ")
+	os.WriteFile(filepath.Join(dir, "quarantine.json"), data, 0600)
+}
+
+// GetQuarantine returns the files that failed verification, most recent
+// first.
+func (s *Service) GetQuarantine() []QuarantineRecord {
+	records := []QuarantineRecord{}
+	if data, err := os.ReadFile(filepath.Join(s.dataDir, "quarantine", "quarantine.json")); err == nil {
+		json.Unmarshal(data, &records)
+	}
+	return records
+}
+
+// pinnedChecksum returns the checksum for verifyDownload of the entry key
+// of the verify.json field named field. Without it the download is refused,
+// naming the entry the operator has to set.
+func pinnedChecksum(hashes map[string]string, field, key string) (string, error) {
+	if h := hashes[key]; h != "" {
+		return "sha256:" + h, nil
+	}
+	return "", fmt.Errorf("%w para %s, defina %s[%q] em verify.json", ErrHashNotPinned, key, field, key)
+}
+
+// downloadGenesis downloads the genesis of chainID to dest and checks it
+// against the hash set for chainID in verify.json.
+func (s *Service) downloadGenesis(ctx context.Context, genesisURL, chainID, dest string) error {
+	checksum, err := pinnedChecksum(s.GetVerifyConfig().GenesisHashes, "genesisHashes", chainID)
+	if err != nil {
+		return fmt.Errorf("genesis não verificado: %w", err)
+	}
+	if err := s.downloadFile(ctx, genesisURL, dest, nil); err != nil {
+		return fmt.Errorf("erro ao baixar genesis: %v", err)
+	}
+	if err := s.verifyDownload(ctx, nil, genesisURL, dest, checksum); err != nil {
+		return fmt.Errorf("genesis não verificado: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package node
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testGenesis = `{"genesis_time":"2024-01-01T00:00:00Z","chain_id":"tickfy-test","initial_height":"1"}`
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// newGenesisServer serves testGenesis and counts the requests for it.
+func newGenesisServer(t *testing.T, requests *int) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		w.Write([]byte(testGenesis))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/network/genesis.json"
+}
+
+func TestDownloadGenesis(t *testing.T) {
+	good := sha256Hex(testGenesis)
+	bad := sha256Hex("another genesis")
+
+	tests := []struct {
+		name       string
+		hashes     map[string]string // verify.json genesisHashes
+		wantErr    error
+		downloaded bool
+		quarantine bool
+	}{
+		{name: "pinned hash", hashes: map[string]string{"tickfy-test": good}, downloaded: true},
+		{name: "pinned hash mismatch", hashes: map[string]string{"tickfy-test": bad}, wantErr: ErrChecksumMismatch, downloaded: true, quarantine: true},
+		{name: "no hash", wantErr: ErrHashNotPinned},
+		{name: "hash for another chain", hashes: map[string]string{"tickfy-other": good}, wantErr: ErrHashNotPinned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newService(t.TempDir(), &fakeRunner{})
+			if tt.hashes != nil {
+				if err := s.SaveVerifyConfig(VerifyConfig{GenesisHashes: tt.hashes}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var requests int
+			dest := filepath.Join(s.dataDir, "genesis.json")
+			err := s.downloadGenesis(context.Background(), newGenesisServer(t, &requests), "tickfy-test", dest)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("downloadGenesis: %v", err)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrHashNotPinned) && !strings.Contains(err.Error(), `genesisHashes["tickfy-test"]`) {
+				t.Errorf("error does not name the verify.json entry: %v", err)
+			}
+
+			if got := requests > 0; got != tt.downloaded {
+				t.Errorf("downloaded = %v, want %v", got, tt.downloaded)
+			}
+			_, statErr := os.Stat(dest)
+			if got := statErr == nil; got != (tt.wantErr == nil) {
+				t.Errorf("genesis in place = %v, want %v", got, tt.wantErr == nil)
+			}
+			if got := len(s.GetQuarantine()) > 0; got != tt.quarantine {
+				t.Errorf("quarantined = %v, want %v", got, tt.quarantine)
+			}
+		})
+	}
+}
@@ -0,0 +1,528 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// BINARY VERSIONS
+// =============================================================================
+
+// Every installed release lives in versions/<version>/ under the data dir
+// and bin/tickfy-blockchaind is a symlink to the active one.
+const defaultReleaseIndex = "https://raw.githubusercontent.com/Tickfy/tickfy-blockchain/main/network/releases.json"
+
+// builtinReleaseBase hosts the release installed when the default index
+// cannot be reached. Its binaries are verified against the SHA256SUMS
+// published there, so the release host still has to be reachable.
+const builtinReleaseBase = "https://github.com/Tickfy/tickfy-blockchain/releases/download/v1.0.0"
+
+var ErrVersionNotInstalled = errors.New("versão não instalada")
+
+// versionRe keeps versions usable as directory names
+var versionRe = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z.+\-]*$`)
+
+// VersionsState is kept in versions.json.
+type VersionsState struct {
+	IndexURL string `json:"indexUrl,omitempty"`
+	Active   string `json:"active,omitempty"`
+	Previous string `json:"previous,omitempty"`
+}
+
+// Release is an entry of the release index, whose binaries use the same
+// "os/arch" -> "url?checksum=sha256:<hex>" map as upgrade plans.
+type Release struct {
+	Version  string            `json:"version"`
+	Date     string            `json:"date,omitempty"`
+	Notes    string            `json:"notes,omitempty"`
+	Binaries map[string]string `json:"binaries"`
+}
+
+type BinaryVersion struct {
+	Version     string `json:"version"`
+	Date        string `json:"date,omitempty"`
+	Notes       string `json:"notes,omitempty"`
+	Available   bool   `json:"available"` // listed in the release index
+	Installed   bool   `json:"installed"`
+	Active      bool   `json:"active"`
+	Previous    bool   `json:"previous"`
+	InstalledAt int64  `json:"installedAt,omitempty"`
+}
+
+type VersionList struct {
+	IndexURL   string          `json:"indexUrl"`
+	IndexError string          `json:"indexError,omitempty"`
+	Active     string          `json:"active,omitempty"`
+	Previous   string          `json:"previous,omitempty"`
+	Versions   []BinaryVersion `json:"versions"`
+}
+
+func (s *Service) loadVersionsState() VersionsState {
+	var state VersionsState
+	if data, err := os.ReadFile(filepath.Join(s.dataDir, "versions.json")); err == nil {
+		json.Unmarshal(data, &state)
+	}
+	if state.IndexURL == "" {
+		state.IndexURL = defaultReleaseIndex
+	}
+	return state
+}
+
+func (s *Service) saveVersionsState(state VersionsState) error {
+	if state.IndexURL == defaultReleaseIndex {
+		state.IndexURL = ""
+	}
+	data, _ := json.MarshalIndent(state, "", "  ")
+	return os.WriteFile(filepath.Join(s.dataDir, "versions.json"), data, 0600)
+}
+
+// SetReleaseIndex changes the URL releases are listed from; empty restores
+// the default.
+func (s *Service) SetReleaseIndex(indexURL string) error {
+	indexURL = strings.TrimSpace(indexURL)
+	if indexURL != "" && !strings.HasPrefix(indexURL, "https://") && !strings.HasPrefix(indexURL, "http://") {
+		return errors.New("URL do índice deve começar com http:// ou https://")
+	}
+
+	s.versionsMutex.Lock()
+	defer s.versionsMutex.Unlock()
+	state := s.loadVersionsState()
+	state.IndexURL = indexURL
+	return s.saveVersionsState(state)
+}
+
+func (s *Service) versionDir(version string) string {
+	return filepath.Join(s.dataDir, "versions", version)
+}
+
+func (s *Service) versionBinary(version string) string {
+	return filepath.Join(s.versionDir(version), filepath.Base(s.getBinaryPath()))
+}
+
+// fetchReleases downloads the release index.
+func (s *Service) fetchReleases(ctx context.Context, indexURL string) ([]Release, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, indexURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao baixar índice de versões: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("erro ao baixar índice de versões (status %d)", resp.StatusCode)
+	}
+
+	var index struct {
+		Releases []Release `json:"releases"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&index); err != nil {
+		return nil, fmt.Errorf("índice de versões inválido: %v", err)
+	}
+
+	releases := index.Releases[:0]
+	for _, r := range index.Releases {
+		if versionRe.MatchString(r.Version) {
+			releases = append(releases, r)
+		}
+	}
+	return releases, nil
+}
+
+// builtinRelease is the release the installer shipped with, so a fresh
+// setup does not depend on the index being published.
+func builtinRelease() Release {
+	return Release{
+		Version: "v1.0.0",
+		Binaries: map[string]string{
+			"linux/amd64":   builtinReleaseBase + "/tickfy-blockchaind-linux-amd64",
+			"darwin/amd64":  builtinReleaseBase + "/tickfy-blockchaind-darwin-amd64",
+			"darwin/arm64":  builtinReleaseBase + "/tickfy-blockchaind-darwin-arm64",
+			"windows/amd64": builtinReleaseBase + "/tickfy-blockchaind-windows-amd64.exe",
+		},
+	}
+}
+
+// listReleases fetches the release index. When it is the default index and
+// cannot be reached, the built-in release is returned along with the error.
+func (s *Service) listReleases(ctx context.Context, indexURL string) ([]Release, error) {
+	releases, err := s.fetchReleases(ctx, indexURL)
+	if err != nil && indexURL == defaultReleaseIndex {
+		return []Release{builtinRelease()}, err
+	}
+	return releases, err
+}
+
+// compareVersions orders dotted versions numerically, ignoring a leading
+// "v"; a pre-release sorts before its release.
+func compareVersions(a, b string) int {
+	a, b = strings.TrimPrefix(a, "v"), strings.TrimPrefix(b, "v")
+	aCore, aPre, _ := strings.Cut(a, "-")
+	bCore, bPre, _ := strings.Cut(b, "-")
+
+	aParts, bParts := strings.Split(aCore, "."), strings.Split(bCore, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var x, y int
+		if i < len(aParts) {
+			x, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			y, _ = strconv.Atoi(bParts[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+	return strings.Compare(aPre, bPre)
+}
+
+// GetVersions lists installed versions together with the releases in the
+// index, newest first. Installed versions, and the built-in release for the
+// default index, are listed even when the index cannot be reached.
+func (s *Service) GetVersions() *VersionList {
+	s.versionsMutex.Lock()
+	s.adoptLegacyBinary()
+	state := s.loadVersionsState()
+	s.versionsMutex.Unlock()
+
+	list := &VersionList{
+		IndexURL: state.IndexURL,
+		Active:   state.Active,
+		Previous: state.Previous,
+		Versions: []BinaryVersion{},
+	}
+	byVersion := make(map[string]*BinaryVersion)
+	entry := func(version string) *BinaryVersion {
+		if v, ok := byVersion[version]; ok {
+			return v
+		}
+		v := &BinaryVersion{
+			Version:  version,
+			Active:   version == state.Active,
+			Previous: version == state.Previous,
+		}
+		byVersion[version] = v
+		return v
+	}
+
+	if entries, err := os.ReadDir(filepath.Join(s.dataDir, "versions")); err == nil {
+		for _, e := range entries {
+			info, err := os.Stat(s.versionBinary(e.Name()))
+			if !e.IsDir() || err != nil {
+				continue
+			}
+			v := entry(e.Name())
+			v.Installed = true
+			v.InstalledAt = info.ModTime().Unix()
+		}
+	}
+
+	releases, err := s.listReleases(context.Background(), state.IndexURL)
+	if err != nil {
+		list.IndexError = err.Error()
+	}
+	for _, r := range releases {
+		if _, err := platformBinary(r.Binaries); err != nil {
+			continue
+		}
+		v := entry(r.Version)
+		v.Available = true
+		v.Date = r.Date
+		v.Notes = r.Notes
+	}
+
+	for _, v := range byVersion {
+		list.Versions = append(list.Versions, *v)
+	}
+	sort.Slice(list.Versions, func(i, j int) bool {
+		return compareVersions(list.Versions[i].Version, list.Versions[j].Version) > 0
+	})
+	return list
+}
+
+// StartInstallVersion installs a release from the index into the version
+// store as a background job. An empty version means the latest release.
+// The active version is only changed when there is none yet.
+func (s *Service) StartInstallVersion(version string) (JobInfo, error) {
+	if version != "" && !versionRe.MatchString(version) {
+		return JobInfo{}, errors.New("versão inválida")
+	}
+	return s.startJob(JobInstallVersion, func(ctx context.Context, job *Job) error {
+		_, err := s.installVersion(ctx, job, version)
+		return err
+	})
+}
+
+func (s *Service) installVersion(ctx context.Context, job *Job, version string) (string, error) {
+	s.versionsMutex.Lock()
+	s.adoptLegacyBinary()
+	state := s.loadVersionsState()
+	s.versionsMutex.Unlock()
+
+	releases, indexErr := s.listReleases(ctx, state.IndexURL)
+	if indexErr != nil {
+		if len(releases) == 0 {
+			return "", indexErr
+		}
+		s.logStep(job, fmt.Sprintf("Release index unavailable, using the built-in release: %v", indexErr))
+	}
+
+	var release *Release
+	for i := range releases {
+		r := &releases[i]
+		if _, err := platformBinary(r.Binaries); err != nil {
+			continue
+		}
+		if version == "" && (release == nil || compareVersions(r.Version, release.Version) > 0) {
+			release = r
+		} else if version != "" && r.Version == version {
+			release = r
+		}
+	}
+	if release == nil {
+		if indexErr != nil {
+			return "", indexErr
+		}
+		if version == "" {
+			return "", fmt.Errorf("nenhuma versão disponível para %s/%s", runtime.GOOS, runtime.GOARCH)
+		}
+		return "", fmt.Errorf("versão %s não encontrada no índice para %s/%s", version, runtime.GOOS, runtime.GOARCH)
+	}
+	version = release.Version
+
+	target := s.versionBinary(version)
+	if _, err := os.Stat(target); err == nil {
+		s.logStep(job, fmt.Sprintf("Version %s already installed", version))
+	} else {
+		binaryURL, _ := platformBinary(release.Binaries)
+		downloadURL, checksum, err := splitChecksum(binaryURL)
+		if err != nil {
+			return "", err
+		}
+		s.logStep(job, fmt.Sprintf("Installing version %s", version))
+		if _, err := s.installBinary(ctx, job, downloadURL, checksum, target); err != nil {
+			s.logStep(job, fmt.Sprintf("Install error: %v", err))
+			return "", err
+		}
+		s.logStep(job, fmt.Sprintf("Version %s installed", version))
+	}
+
+	s.versionsMutex.Lock()
+	defer s.versionsMutex.Unlock()
+	if s.loadVersionsState().Active == "" {
+		if err := s.activateVersion(version); err != nil {
+			return "", err
+		}
+		s.logStep(job, fmt.Sprintf("Version %s is now active", version))
+	}
+	return version, nil
+}
+
+// ActivateVersion points bin/tickfy-blockchaind at an installed version and
+// remembers the one it replaces for RollbackVersion. Under Cosmovisor the
+// version is also linked into its current upgrade, which is what the node
+// runs. The node has to be stopped, as it keeps running the binary it was
+// started with.
+func (s *Service) ActivateVersion(version string) error {
+	if !versionRe.MatchString(version) {
+		return errors.New("versão inválida")
+	}
+
+	// StartNode takes s.versionsMutex too, so the node cannot start between
+	// this check and the relink
+	s.versionsMutex.Lock()
+	defer s.versionsMutex.Unlock()
+	if s.isNodeRunning() {
+		return errors.New("pare o node antes de trocar a versão")
+	}
+	s.adoptLegacyBinary()
+	if s.IsCosmovisorEnabled() {
+		if _, err := os.Stat(s.versionBinary(version)); err != nil {
+			return ErrVersionNotInstalled
+		}
+		if err := s.linkCosmovisorCurrent(version); err != nil {
+			return err
+		}
+	}
+	if s.loadVersionsState().Active == version {
+		return nil
+	}
+	return s.activateVersion(version)
+}
+
+// linkCosmovisorCurrent links version into the bin dir of the upgrade
+// Cosmovisor currently runs. It must be called with s.versionsMutex held.
+func (s *Service) linkCosmovisorCurrent(version string) error {
+	current, err := filepath.EvalSymlinks(filepath.Join(s.getNodeHome(), "cosmovisor", "current"))
+	if err != nil {
+		return fmt.Errorf("diretório current do Cosmovisor não encontrado: %v", err)
+	}
+	if err := linkBinary(s.versionBinary(version), filepath.Join(current, "bin", filepath.Base(s.getBinaryPath()))); err != nil {
+		return fmt.Errorf("erro ao ativar versão no Cosmovisor: %v", err)
+	}
+	s.addLog(fmt.Sprintf("Binary version %s linked into Cosmovisor %s", version, filepath.Base(current)))
+	return nil
+}
+
+// RollbackVersion activates the version that was active before the current
+// one.
+func (s *Service) RollbackVersion() (string, error) {
+	s.versionsMutex.Lock()
+	s.adoptLegacyBinary()
+	previous := s.loadVersionsState().Previous
+	s.versionsMutex.Unlock()
+
+	if previous == "" {
+		return "", errors.New("não há versão anterior para restaurar")
+	}
+	if err := s.ActivateVersion(previous); err != nil {
+		return "", err
+	}
+	return previous, nil
+}
+
+// activateVersion must be called with s.versionsMutex held.
+func (s *Service) activateVersion(version string) error {
+	target := s.versionBinary(version)
+	if _, err := os.Stat(target); err != nil {
+		return ErrVersionNotInstalled
+	}
+
+	binaryPath := s.getBinaryPath()
+	os.MkdirAll(filepath.Dir(binaryPath), 0755)
+	if err := linkBinary(target, binaryPath); err != nil {
+		return fmt.Errorf("erro ao ativar versão: %v", err)
+	}
+
+	state := s.loadVersionsState()
+	if state.Active != version {
+		state.Previous = state.Active
+	}
+	state.Active = version
+	if err := s.saveVersionsState(state); err != nil {
+		return err
+	}
+
+	s.addLog(fmt.Sprintf("Binary version %s activated", version))
+	return nil
+}
+
+// linkBinary replaces link with a symlink to target through a rename, so
+// the binary path is never missing. Windows gets a copy, as symlinks there
+// need extra privileges.
+func linkBinary(target, link string) error {
+	tmp := link + ".new"
+	os.Remove(tmp)
+
+	if runtime.GOOS == "windows" {
+		data, err := os.ReadFile(target)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(tmp, data, 0755); err != nil {
+			return err
+		}
+	} else if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp, link)
+}
+
+// adoptLegacyBinary moves a binary installed before the version store into
+// it, under the version it reports, and links it back in place. It must be
+// called with s.versionsMutex held.
+func (s *Service) adoptLegacyBinary() {
+	binaryPath := s.getBinaryPath()
+	info, err := os.Lstat(binaryPath)
+	if err != nil || !info.Mode().IsRegular() || runtime.GOOS == "windows" {
+		return
+	}
+
+	version, err := dryRunBinary(context.Background(), binaryPath)
+	if err != nil || !versionRe.MatchString(version) {
+		version = "legacy"
+	}
+	if _, err := os.Stat(s.versionBinary(version)); err == nil {
+		version += "-" + strconv.FormatInt(info.ModTime().Unix(), 10)
+	}
+
+	os.MkdirAll(s.versionDir(version), 0755)
+	if err := os.Rename(binaryPath, s.versionBinary(version)); err != nil {
+		s.addLog(fmt.Sprintf("Could not move binary into the version store: %v", err))
+		return
+	}
+	if err := s.activateVersion(version); err != nil {
+		s.addLog(fmt.Sprintf("Could not activate adopted binary %s: %v", version, err))
+		return
+	}
+	s.addLog(fmt.Sprintf("Existing binary adopted as version %s", version))
+}
+
+// runningBinaryPath is the binary the node runs: Cosmovisor's current
+// one when it is enabled, the active version otherwise.
+func (s *Service) runningBinaryPath() string {
+	if s.IsCosmovisorEnabled() {
+		path := filepath.Join(s.getNodeHome(), "cosmovisor", "current", "bin", filepath.Base(s.getBinaryPath()))
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return s.getBinaryPath()
+}
+
+// nodeVersion returns what `tickfy-blockchaind version` prints for the
+// running binary. It is cached until the binary changes, since the status
+// is polled.
+func (s *Service) nodeVersion() string {
+	path, err := filepath.EvalSymlinks(s.runningBinaryPath())
+	if err != nil {
+		return ""
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	key := fmt.Sprintf("%s@%d", path, info.ModTime().UnixNano())
+
+	s.versionsMutex.Lock()
+	defer s.versionsMutex.Unlock()
+	if s.nodeVersionKey == key {
+		return s.nodeVersionValue
+	}
+
+	version, err := dryRunBinary(context.Background(), path)
+	if err != nil {
+		version = ""
+	}
+	s.nodeVersionKey = key
+	s.nodeVersionValue = version
+	return version
+}
@@ -0,0 +1,306 @@
+package tx
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// =============================================================================
+// REST CLIENT
+// =============================================================================
+
+var (
+	ErrNotFound   = errors.New("não encontrado")
+	ErrTxNotFound = errors.New("transação não encontrada")
+)
+
+// Client talks to the REST (gRPC gateway) endpoint of a node, usually the
+// local one on :1317.
+type Client struct {
+	endpoint string
+	chainID  string
+
+	HTTPClient    *http.Client
+	GasAdjustment float64
+	GasPrices     sdk.DecCoins
+}
+
+func NewClient(endpoint, chainID string) *Client {
+	return &Client{
+		endpoint:      strings.TrimRight(endpoint, "/"),
+		chainID:       chainID,
+		HTTPClient:    &http.Client{Timeout: 30 * time.Second},
+		GasAdjustment: 1.3,
+	}
+}
+
+func (c *Client) ChainID() string {
+	return c.chainID
+}
+
+// apiError is the error body of the gRPC gateway.
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("erro ao acessar a API do node: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr apiError
+		json.Unmarshal(data, &apiErr)
+		// gRPC NotFound
+		if resp.StatusCode == http.StatusNotFound || apiErr.Code == 5 {
+			return fmt.Errorf("%w: %s", ErrNotFound, apiErr.Message)
+		}
+		if apiErr.Message != "" {
+			return errors.New(apiErr.Message)
+		}
+		return fmt.Errorf("API do node respondeu %s", resp.Status)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// Query GETs a REST path, such as /cosmos/staking/v1beta1/params, into out.
+func (c *Client) Query(ctx context.Context, path string, out interface{}) error {
+	return c.do(ctx, http.MethodGet, path, nil, out)
+}
+
+// Account holds what a signer needs from x/auth.
+type Account struct {
+	Address       string
+	AccountNumber uint64
+	Sequence      uint64
+}
+
+func (c *Client) Account(ctx context.Context, address string) (*Account, error) {
+	type baseAccount struct {
+		Address       string `json:"address"`
+		AccountNumber uint64 `json:"account_number,string"`
+		Sequence      uint64 `json:"sequence,string"`
+	}
+	var resp struct {
+		Account struct {
+			baseAccount
+			// Vesting accounts wrap the base account
+			BaseVestingAccount *struct {
+				BaseAccount baseAccount `json:"base_account"`
+			} `json:"base_vesting_account"`
+		} `json:"account"`
+	}
+
+	if err := c.Query(ctx, "/cosmos/auth/v1beta1/accounts/"+address, &resp); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("conta %s não existe na chain, envie fundos para ela primeiro", address)
+		}
+		return nil, err
+	}
+
+	base := resp.Account.baseAccount
+	if resp.Account.BaseVestingAccount != nil {
+		base = resp.Account.BaseVestingAccount.BaseAccount
+	}
+	return &Account{
+		Address:       base.Address,
+		AccountNumber: base.AccountNumber,
+		Sequence:      base.Sequence,
+	}, nil
+}
+
+// Simulate returns the gas used by the encoded transaction.
+func (c *Client) Simulate(ctx context.Context, txBytes []byte) (uint64, error) {
+	var resp struct {
+		GasInfo struct {
+			GasUsed uint64 `json:"gas_used,string"`
+		} `json:"gas_info"`
+	}
+	body := map[string]string{"tx_bytes": base64.StdEncoding.EncodeToString(txBytes)}
+	if err := c.do(ctx, http.MethodPost, "/cosmos/tx/v1beta1/simulate", body, &resp); err != nil {
+		return 0, fmt.Errorf("erro na simulação: %v", err)
+	}
+	return resp.GasInfo.GasUsed, nil
+}
+
+// txResponse is the TxResponse of the gRPC gateway.
+type txResponse struct {
+	Height    int64  `json:"height,string"`
+	TxHash    string `json:"txhash"`
+	Codespace string `json:"codespace"`
+	Code      uint32 `json:"code"`
+	RawLog    string `json:"raw_log"`
+	GasWanted int64  `json:"gas_wanted,string"`
+	GasUsed   int64  `json:"gas_used,string"`
+	Data      string `json:"data"`
+}
+
+func (r txResponse) result() *Result {
+	return &Result{
+		TxHash:    r.TxHash,
+		Code:      r.Code,
+		Codespace: r.Codespace,
+		RawLog:    r.RawLog,
+		GasWanted: r.GasWanted,
+		GasUsed:   r.GasUsed,
+		Height:    r.Height,
+		data:      r.Data,
+	}
+}
+
+// Broadcast submits the encoded transaction in sync mode, so the result
+// only reflects CheckTx.
+func (c *Client) Broadcast(ctx context.Context, txBytes []byte) (*Result, error) {
+	var resp struct {
+		TxResponse txResponse `json:"tx_response"`
+	}
+	body := map[string]string{
+		"tx_bytes": base64.StdEncoding.EncodeToString(txBytes),
+		"mode":     "BROADCAST_MODE_SYNC",
+	}
+	if err := c.do(ctx, http.MethodPost, "/cosmos/tx/v1beta1/txs", body, &resp); err != nil {
+		return nil, fmt.Errorf("erro ao transmitir transação: %v", err)
+	}
+	return resp.TxResponse.result(), nil
+}
+
+// GetTx looks up a transaction that is already in a block.
+func (c *Client) GetTx(ctx context.Context, hash string) (*Result, error) {
+	var resp struct {
+		TxResponse txResponse `json:"tx_response"`
+	}
+	if err := c.Query(ctx, "/cosmos/tx/v1beta1/txs/"+hash, &resp); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrTxNotFound
+		}
+		return nil, err
+	}
+	return resp.TxResponse.result(), nil
+}
+
+// WaitForTx polls until the transaction is in a block or ctx is done.
+func (c *Client) WaitForTx(ctx context.Context, hash string) (*Result, error) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		result, err := c.GetTx(ctx, hash)
+		if err == nil {
+			return result, result.Err()
+		}
+		if !errors.Is(err, ErrTxNotFound) {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("transação %s não foi incluída em um bloco: %w", hash, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// SearchedTx is a transaction found by SearchTxs.
+type SearchedTx struct {
+	Result
+	Timestamp string
+	// Messages are the type URLs of the messages in the tx
+	Messages []string
+	Memo     string
+}
+
+// SearchTxs pages through the transactions matching a CometBFT event query
+// such as message.sender='tickfy1...', newest first.
+func (c *Client) SearchTxs(ctx context.Context, query string, page, limit int) ([]SearchedTx, int, error) {
+	var resp struct {
+		TxResponses []struct {
+			txResponse
+			Timestamp string `json:"timestamp"`
+			Tx        struct {
+				Body struct {
+					Messages []struct {
+						Type string `json:"@type"`
+					} `json:"messages"`
+					Memo string `json:"memo"`
+				} `json:"body"`
+				AuthInfo struct {
+					Fee struct {
+						Amount []struct {
+							Denom  string `json:"denom"`
+							Amount string `json:"amount"`
+						} `json:"amount"`
+					} `json:"fee"`
+				} `json:"auth_info"`
+			} `json:"tx"`
+		} `json:"tx_responses"`
+		Total int `json:"total,string"`
+	}
+
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("page", fmt.Sprint(page))
+	params.Set("limit", fmt.Sprint(limit))
+	params.Set("order_by", "ORDER_BY_DESC")
+	if err := c.Query(ctx, "/cosmos/tx/v1beta1/txs?"+params.Encode(), &resp); err != nil {
+		return nil, 0, fmt.Errorf("erro na busca de transações: %v", err)
+	}
+
+	txs := make([]SearchedTx, 0, len(resp.TxResponses))
+	for _, r := range resp.TxResponses {
+		found := SearchedTx{
+			Result:    *r.result(),
+			Timestamp: r.Timestamp,
+			Memo:      r.Tx.Body.Memo,
+		}
+		for _, m := range r.Tx.Body.Messages {
+			found.Messages = append(found.Messages, m.Type)
+		}
+		var fee []string
+		for _, f := range r.Tx.AuthInfo.Fee.Amount {
+			fee = append(fee, f.Amount+f.Denom)
+		}
+		found.Fee = strings.Join(fee, ",")
+		txs = append(txs, found)
+	}
+	return txs, resp.Total, nil
+}
@@ -0,0 +1,339 @@
+package tx
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sdkmath "cosmossdk.io/math"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	signingtypes "github.com/cosmos/cosmos-sdk/types/tx/signing"
+	authsigning "github.com/cosmos/cosmos-sdk/x/auth/signing"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/cosmos/gogoproto/proto"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// fakeLCD stands in for the REST endpoint of a node on :1317.
+type fakeLCD struct {
+	t *testing.T
+
+	mu         sync.Mutex
+	accounts   map[string]string // address -> "account" JSON
+	gasUsed    string
+	checkCode  uint32
+	broadcasts []string // base64 tx_bytes
+	// tx lookups answer NotFound this many times before the tx shows up
+	pendingLookups int
+	blockCode      uint32
+	blockData      string // hex TxMsgData of the tx in the block
+}
+
+func newFakeLCD(t *testing.T) (*fakeLCD, *Client) {
+	lcd := &fakeLCD{t: t, accounts: make(map[string]string), gasUsed: "100000"}
+	server := httptest.NewServer(lcd)
+	t.Cleanup(server.Close)
+
+	client := NewClient(server.URL+"/", "tickfy-test")
+	client.GasPrices = sdk.NewDecCoins(sdk.NewDecCoinFromDec("utkfy", sdkmath.LegacyMustNewDecFromStr("0.025")))
+	return lcd, client
+}
+
+func (l *fakeLCD) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	notFound := func(msg string) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 5, "message": msg})
+	}
+	var body struct {
+		TxBytes string `json:"tx_bytes"`
+		Mode    string `json:"mode"`
+	}
+	if r.Method == http.MethodPost {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/cosmos/auth/v1beta1/accounts/"):
+		account, ok := l.accounts[strings.TrimPrefix(r.URL.Path, "/cosmos/auth/v1beta1/accounts/")]
+		if !ok {
+			notFound("account not found")
+			return
+		}
+		w.Write([]byte(`{"account":` + account + `}`))
+
+	case r.URL.Path == "/cosmos/tx/v1beta1/simulate":
+		w.Write([]byte(`{"gas_info":{"gas_wanted":"0","gas_used":"` + l.gasUsed + `"}}`))
+
+	case r.URL.Path == "/cosmos/tx/v1beta1/txs" && r.Method == http.MethodPost:
+		if body.Mode != "BROADCAST_MODE_SYNC" {
+			l.t.Errorf("broadcast mode = %q", body.Mode)
+		}
+		l.broadcasts = append(l.broadcasts, body.TxBytes)
+		json.NewEncoder(w).Encode(map[string]interface{}{"tx_response": map[string]interface{}{
+			"txhash":    "A1B2",
+			"code":      l.checkCode,
+			"raw_log":   rawLog(l.checkCode),
+			"codespace": codespace(l.checkCode),
+		}})
+
+	case strings.HasPrefix(r.URL.Path, "/cosmos/tx/v1beta1/txs/"):
+		if l.pendingLookups > 0 {
+			l.pendingLookups--
+			notFound("tx not found")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"tx_response": map[string]interface{}{
+			"height":     "42",
+			"txhash":     strings.TrimPrefix(r.URL.Path, "/cosmos/tx/v1beta1/txs/"),
+			"code":       l.blockCode,
+			"raw_log":    rawLog(l.blockCode),
+			"codespace":  codespace(l.blockCode),
+			"gas_wanted": "130000",
+			"gas_used":   "98000",
+			"data":       l.blockData,
+		}})
+
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+		w.Write([]byte(`{"code":12,"message":"Not Implemented"}`))
+	}
+}
+
+func rawLog(code uint32) string {
+	if code == 0 {
+		return ""
+	}
+	return "insufficient funds"
+}
+
+func codespace(code uint32) string {
+	if code == 0 {
+		return ""
+	}
+	return "sdk"
+}
+
+func testKey(t *testing.T) *Key {
+	t.Helper()
+	key, err := KeyFromMnemonic(testMnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func testMsg(key *Key) sdk.Msg {
+	return banktypes.NewMsgSend(key.AccAddress(), key.AccAddress(), sdk.NewCoins(sdk.NewInt64Coin("utkfy", 1000)))
+}
+
+func TestAccount(t *testing.T) {
+	lcd, client := newFakeLCD(t)
+	lcd.accounts["tickfy1base"] = `{"@type":"/cosmos.auth.v1beta1.BaseAccount","address":"tickfy1base","account_number":"7","sequence":"3"}`
+	lcd.accounts["tickfy1vesting"] = `{"@type":"/cosmos.vesting.v1beta1.ContinuousVestingAccount","base_vesting_account":{"base_account":{"address":"tickfy1vesting","account_number":"9","sequence":"1"}}}`
+
+	tests := []struct {
+		address string
+		want    Account
+	}{
+		{"tickfy1base", Account{Address: "tickfy1base", AccountNumber: 7, Sequence: 3}},
+		{"tickfy1vesting", Account{Address: "tickfy1vesting", AccountNumber: 9, Sequence: 1}},
+	}
+	for _, tt := range tests {
+		account, err := client.Account(context.Background(), tt.address)
+		if err != nil {
+			t.Fatalf("Account(%s): %v", tt.address, err)
+		}
+		if *account != tt.want {
+			t.Errorf("Account(%s) = %+v, want %+v", tt.address, *account, tt.want)
+		}
+	}
+
+	if _, err := client.Account(context.Background(), "tickfy1missing"); err == nil || !strings.Contains(err.Error(), "não existe na chain") {
+		t.Errorf("missing account error = %v", err)
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	lcd, client := newFakeLCD(t)
+	lcd.gasUsed = "123456"
+
+	gas, err := client.Simulate(context.Background(), []byte("tx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gas != 123456 {
+		t.Errorf("gas = %d, want 123456", gas)
+	}
+}
+
+func TestSendBroadcastsSignedTx(t *testing.T) {
+	lcd, client := newFakeLCD(t)
+	key := testKey(t)
+	lcd.accounts[key.Address()] = `{"address":"` + key.Address() + `","account_number":"7","sequence":"3"}`
+
+	result, err := client.Send(context.Background(), key, []sdk.Msg{testMsg(key)}, Options{Memo: "test"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if result.TxHash != "A1B2" {
+		t.Errorf("TxHash = %q", result.TxHash)
+	}
+	// 100000 simulated gas * 1.3, at 0.025utkfy
+	if result.Fee != "3250utkfy" {
+		t.Errorf("Fee = %q, want 3250utkfy", result.Fee)
+	}
+
+	if len(lcd.broadcasts) != 1 {
+		t.Fatalf("%d broadcasts, want 1", len(lcd.broadcasts))
+	}
+	txBytes, _ := base64.StdEncoding.DecodeString(lcd.broadcasts[0])
+	_, config := encoding()
+	decoded, err := config.TxDecoder()(txBytes)
+	if err != nil {
+		t.Fatalf("decoding broadcast tx: %v", err)
+	}
+	signed := decoded.(authsigning.Tx)
+	if signed.GetGas() != 130000 {
+		t.Errorf("gas limit = %d, want 130000", signed.GetGas())
+	}
+	if signed.GetMemo() != "test" {
+		t.Errorf("memo = %q", signed.GetMemo())
+	}
+	sigs, err := signed.GetSignaturesV2()
+	if err != nil || len(sigs) != 1 {
+		t.Fatalf("signatures = %v, %v", sigs, err)
+	}
+	if sigs[0].Sequence != 3 {
+		t.Errorf("signature sequence = %d, want 3", sigs[0].Sequence)
+	}
+
+	signerData := authsigning.SignerData{
+		Address:       key.Address(),
+		ChainID:       "tickfy-test",
+		AccountNumber: 7,
+		Sequence:      3,
+		PubKey:        sigs[0].PubKey,
+	}
+	signBytes, err := authsigning.GetSignBytesAdapter(context.Background(), config.SignModeHandler(), signingtypes.SignMode_SIGN_MODE_DIRECT, signerData, signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := sigs[0].Data.(*signingtypes.SingleSignatureData)
+	if !sigs[0].PubKey.VerifySignature(signBytes, data.Signature) {
+		t.Error("signature does not verify for chain tickfy-test, account 7, sequence 3")
+	}
+}
+
+func TestSendCheckTxError(t *testing.T) {
+	lcd, client := newFakeLCD(t)
+	key := testKey(t)
+	lcd.accounts[key.Address()] = `{"address":"` + key.Address() + `","account_number":"7","sequence":"3"}`
+	lcd.checkCode = 5
+
+	result, err := client.Send(context.Background(), key, []sdk.Msg{testMsg(key)}, Options{})
+	var txErr *TxError
+	if !errors.As(err, &txErr) {
+		t.Fatalf("error = %v, want *TxError", err)
+	}
+	if txErr.Code != 5 || txErr.Codespace != "sdk" || txErr.RawLog != "insufficient funds" {
+		t.Errorf("TxError = %+v", txErr)
+	}
+	// The hash is still returned with the error
+	if result == nil || result.TxHash != "A1B2" {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestWaitForTx(t *testing.T) {
+	lcd, client := newFakeLCD(t)
+	lcd.pendingLookups = 1
+
+	result, err := client.WaitForTx(context.Background(), "A1B2")
+	if err != nil {
+		t.Fatalf("WaitForTx: %v", err)
+	}
+	if result.Height != 42 || result.GasUsed != 98000 {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestWaitForTxFailedInBlock(t *testing.T) {
+	lcd, client := newFakeLCD(t)
+	lcd.blockCode = 5
+
+	result, err := client.WaitForTx(context.Background(), "A1B2")
+	var txErr *TxError
+	if !errors.As(err, &txErr) || txErr.Code != 5 {
+		t.Fatalf("error = %v, want *TxError with code 5", err)
+	}
+	if result == nil || result.Height != 42 {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestWaitForTxTimeout(t *testing.T) {
+	lcd, client := newFakeLCD(t)
+	lcd.pendingLookups = 1 << 30
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := client.WaitForTx(ctx, "A1B2")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestMsgResponses(t *testing.T) {
+	lcd, client := newFakeLCD(t)
+	completion := time.Date(2026, 11, 6, 12, 0, 0, 0, time.UTC)
+	response, err := codectypes.NewAnyWithValue(&stakingtypes.MsgUndelegateResponse{
+		CompletionTime: completion,
+		Amount:         sdk.NewInt64Coin("utkfy", 1000),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := proto.Marshal(&sdk.TxMsgData{MsgResponses: []*codectypes.Any{response}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lcd.blockData = strings.ToUpper(hex.EncodeToString(data))
+
+	result, err := client.WaitForTx(context.Background(), "A1B2")
+	if err != nil {
+		t.Fatalf("WaitForTx: %v", err)
+	}
+	responses, err := result.MsgResponses()
+	if err != nil {
+		t.Fatalf("MsgResponses: %v", err)
+	}
+	if len(responses) != 1 {
+		t.Fatalf("%d responses, want 1", len(responses))
+	}
+	undelegate, ok := responses[0].(*stakingtypes.MsgUndelegateResponse)
+	if !ok {
+		t.Fatalf("response = %T, want *MsgUndelegateResponse", responses[0])
+	}
+	if !undelegate.CompletionTime.Equal(completion) {
+		t.Errorf("CompletionTime = %s, want %s", undelegate.CompletionTime, completion)
+	}
+
+	// A broadcast result carries no responses
+	if responses, err := (&Result{TxHash: "A1B2"}).MsgResponses(); err != nil || responses != nil {
+		t.Errorf("MsgResponses without data = %v, %v", responses, err)
+	}
+}
@@ -0,0 +1,366 @@
+package tx
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"cosmossdk.io/x/tx/signing"
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/codec"
+	"github.com/cosmos/cosmos-sdk/codec/address"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	"github.com/cosmos/cosmos-sdk/crypto/hd"
+	"github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
+	"github.com/cosmos/cosmos-sdk/std"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/bech32"
+	signingtypes "github.com/cosmos/cosmos-sdk/types/tx/signing"
+	authsigning "github.com/cosmos/cosmos-sdk/x/auth/signing"
+	authtx "github.com/cosmos/cosmos-sdk/x/auth/tx"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	authz "github.com/cosmos/cosmos-sdk/x/authz"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	distrtypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
+	govv1 "github.com/cosmos/cosmos-sdk/x/gov/types/v1"
+	govv1beta1 "github.com/cosmos/cosmos-sdk/x/gov/types/v1beta1"
+	slashingtypes "github.com/cosmos/cosmos-sdk/x/slashing/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	"github.com/cosmos/go-bip39"
+	"github.com/cosmos/gogoproto/proto"
+)
+
+const (
+	AccountPrefix   = "tickfy"
+	ValidatorPrefix = "tickfyvaloper"
+	ConsensusPrefix = "tickfyvalcons"
+	HDPath          = "m/44'/118'/0'/0/0"
+)
+
+// =============================================================================
+// ENCODING
+// =============================================================================
+
+var (
+	encodingOnce sync.Once
+	cdc          codec.Codec
+	txConfig     client.TxConfig
+)
+
+func encoding() (codec.Codec, client.TxConfig) {
+	encodingOnce.Do(func() {
+		registry, err := codectypes.NewInterfaceRegistryWithOptions(codectypes.InterfaceRegistryOptions{
+			ProtoFiles: proto.HybridResolver,
+			SigningOptions: signing.Options{
+				AddressCodec:          address.NewBech32Codec(AccountPrefix),
+				ValidatorAddressCodec: address.NewBech32Codec(ValidatorPrefix),
+			},
+		})
+		if err != nil {
+			panic(err)
+		}
+
+		std.RegisterInterfaces(registry)
+		authtypes.RegisterInterfaces(registry)
+		authz.RegisterInterfaces(registry)
+		banktypes.RegisterInterfaces(registry)
+		distrtypes.RegisterInterfaces(registry)
+		govv1.RegisterInterfaces(registry)
+		govv1beta1.RegisterInterfaces(registry)
+		slashingtypes.RegisterInterfaces(registry)
+		stakingtypes.RegisterInterfaces(registry)
+
+		cdc = codec.NewProtoCodec(registry)
+		txConfig = authtx.NewTxConfig(cdc, []signingtypes.SignMode{signingtypes.SignMode_SIGN_MODE_DIRECT})
+	})
+	return cdc, txConfig
+}
+
+// Codec returns the proto codec with every message type the tool sends
+// registered, for decoding query responses that carry Any values.
+func Codec() codec.Codec {
+	c, _ := encoding()
+	return c
+}
+
+// =============================================================================
+// KEYS
+// =============================================================================
+
+// Key is the secp256k1 key of a wallet, derived from its mnemonic on the
+// standard Cosmos path. It is only kept for the length of a transaction.
+type Key struct {
+	priv *secp256k1.PrivKey
+}
+
+func KeyFromMnemonic(mnemonic string) (*Key, error) {
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return nil, errors.New("mnemonic inválido")
+	}
+
+	seed := bip39.NewSeed(mnemonic, "")
+	master, ch := hd.ComputeMastersFromSeed(seed)
+	derived, err := hd.DerivePrivateKeyForPath(master, ch, HDPath)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{priv: &secp256k1.PrivKey{Key: derived}}, nil
+}
+
+func (k *Key) AccAddress() sdk.AccAddress {
+	return sdk.AccAddress(k.priv.PubKey().Address())
+}
+
+// Address is the tickfy1... account address.
+func (k *Key) Address() string {
+	addr, _ := bech32.ConvertAndEncode(AccountPrefix, k.AccAddress())
+	return addr
+}
+
+// ValidatorAddress is the tickfyvaloper1... operator address of the same key.
+func (k *Key) ValidatorAddress() string {
+	addr, _ := bech32.ConvertAndEncode(ValidatorPrefix, k.AccAddress())
+	return addr
+}
+
+// ValidatorAddressOf converts a tickfy1... account address into the
+// tickfyvaloper1... address of the same account.
+func ValidatorAddressOf(address string) (string, error) {
+	prefix, bz, err := bech32.DecodeAndConvert(address)
+	if err != nil {
+		return "", fmt.Errorf("endereço inválido: %v", err)
+	}
+	if prefix != AccountPrefix {
+		return "", fmt.Errorf("endereço deve começar com %s", AccountPrefix)
+	}
+	return bech32.ConvertAndEncode(ValidatorPrefix, bz)
+}
+
+// ValidateAddress checks that address is valid bech32 with the given prefix.
+func ValidateAddress(address, prefix string) error {
+	got, bz, err := bech32.DecodeAndConvert(address)
+	if err != nil {
+		return fmt.Errorf("endereço inválido: %v", err)
+	}
+	if got != prefix {
+		return fmt.Errorf("endereço deve começar com %s", prefix)
+	}
+	if len(bz) != 20 && len(bz) != 32 {
+		return fmt.Errorf("endereço inválido: tamanho %d", len(bz))
+	}
+	return nil
+}
+
+// =============================================================================
+// BUILD AND SIGN
+// =============================================================================
+
+// Options tune a transaction. With GasLimit zero the gas is simulated and
+// multiplied by GasAdjustment; with Fees empty they are GasPrices times the
+// gas limit.
+type Options struct {
+	Memo          string
+	GasLimit      uint64
+	GasAdjustment float64
+	GasPrices     sdk.DecCoins
+	Fees          sdk.Coins
+}
+
+// Result is the outcome of a broadcast, or of a transaction looked up by
+// hash once it is in a block.
+type Result struct {
+	TxHash    string `json:"txHash"`
+	Code      uint32 `json:"code"`
+	Codespace string `json:"codespace,omitempty"`
+	RawLog    string `json:"rawLog,omitempty"`
+	GasWanted int64  `json:"gasWanted"`
+	GasUsed   int64  `json:"gasUsed"`
+	Height    int64  `json:"height"`
+	Fee       string `json:"fee,omitempty"`
+
+	// data is the hex encoded TxMsgData of a transaction in a block
+	data string
+}
+
+// Err returns a *TxError when the chain rejected the transaction.
+func (r *Result) Err() error {
+	if r.Code == 0 {
+		return nil
+	}
+	return &TxError{Code: r.Code, Codespace: r.Codespace, RawLog: r.RawLog}
+}
+
+// MsgResponses decodes the responses of the messages in a transaction, in
+// message order. It returns nil when the result does not come from a
+// block, as CheckTx runs no messages.
+func (r *Result) MsgResponses() ([]proto.Message, error) {
+	if r.data == "" {
+		return nil, nil
+	}
+	bz, err := hex.DecodeString(r.data)
+	if err != nil {
+		return nil, fmt.Errorf("dados da transação inválidos: %v", err)
+	}
+	var msgData sdk.TxMsgData
+	if err := proto.Unmarshal(bz, &msgData); err != nil {
+		return nil, fmt.Errorf("dados da transação inválidos: %v", err)
+	}
+
+	cdc, _ := encoding()
+	responses := make([]proto.Message, 0, len(msgData.MsgResponses))
+	for _, packed := range msgData.MsgResponses {
+		msg, err := cdc.InterfaceRegistry().Resolve(packed.TypeUrl)
+		if err != nil {
+			return nil, err
+		}
+		if err := proto.Unmarshal(packed.Value, msg); err != nil {
+			return nil, fmt.Errorf("resposta %s inválida: %v", packed.TypeUrl, err)
+		}
+		responses = append(responses, msg)
+	}
+	return responses, nil
+}
+
+type TxError struct {
+	Code      uint32
+	Codespace string
+	RawLog    string
+}
+
+func (e *TxError) Error() string {
+	return fmt.Sprintf("transação rejeitada (%s %d): %s", e.Codespace, e.Code, e.RawLog)
+}
+
+// buildTx encodes msgs signed by key. Passing sign false leaves an empty
+// signature, which is enough for simulation.
+func buildTx(ctx context.Context, key *Key, chainID string, account *Account, msgs []sdk.Msg, gas uint64, fees sdk.Coins, memo string, sign bool) ([]byte, error) {
+	_, config := encoding()
+
+	builder := config.NewTxBuilder()
+	if err := builder.SetMsgs(msgs...); err != nil {
+		return nil, err
+	}
+	builder.SetMemo(memo)
+	builder.SetGasLimit(gas)
+	builder.SetFeeAmount(fees)
+
+	// The signer info has to be in place before the sign bytes are computed
+	sig := signingtypes.SignatureV2{
+		PubKey:   key.priv.PubKey(),
+		Data:     &signingtypes.SingleSignatureData{SignMode: signingtypes.SignMode_SIGN_MODE_DIRECT},
+		Sequence: account.Sequence,
+	}
+	if err := builder.SetSignatures(sig); err != nil {
+		return nil, err
+	}
+
+	if sign {
+		signerData := authsigning.SignerData{
+			Address:       key.Address(),
+			ChainID:       chainID,
+			AccountNumber: account.AccountNumber,
+			Sequence:      account.Sequence,
+			PubKey:        key.priv.PubKey(),
+		}
+		bytesToSign, err := authsigning.GetSignBytesAdapter(ctx, config.SignModeHandler(), signingtypes.SignMode_SIGN_MODE_DIRECT, signerData, builder.GetTx())
+		if err != nil {
+			return nil, err
+		}
+		signature, err := key.priv.Sign(bytesToSign)
+		if err != nil {
+			return nil, err
+		}
+		sig.Data = &signingtypes.SingleSignatureData{
+			SignMode:  signingtypes.SignMode_SIGN_MODE_DIRECT,
+			Signature: signature,
+		}
+		if err := builder.SetSignatures(sig); err != nil {
+			return nil, err
+		}
+	}
+
+	return config.TxEncoder()(builder.GetTx())
+}
+
+// feesFor multiplies the gas prices by the gas limit, rounding up.
+func feesFor(prices sdk.DecCoins, gas uint64) sdk.Coins {
+	fees := sdk.NewCoins()
+	for _, price := range prices {
+		amount := price.Amount.MulInt64(int64(gas)).Ceil().TruncateInt()
+		fees = fees.Add(sdk.NewCoin(price.Denom, amount))
+	}
+	return fees
+}
+
+// Estimate simulates msgs and returns the gas limit and fees that Send would
+// use with the same options.
+func (c *Client) Estimate(ctx context.Context, key *Key, msgs []sdk.Msg, opts Options) (uint64, sdk.Coins, error) {
+	account, err := c.Account(ctx, key.Address())
+	if err != nil {
+		return 0, nil, err
+	}
+	return c.estimate(ctx, key, account, msgs, opts)
+}
+
+func (c *Client) estimate(ctx context.Context, key *Key, account *Account, msgs []sdk.Msg, opts Options) (uint64, sdk.Coins, error) {
+	gas := opts.GasLimit
+	if gas == 0 {
+		simBytes, err := buildTx(ctx, key, c.chainID, account, msgs, 0, opts.Fees, opts.Memo, false)
+		if err != nil {
+			return 0, nil, err
+		}
+		used, err := c.Simulate(ctx, simBytes)
+		if err != nil {
+			return 0, nil, err
+		}
+		adjustment := opts.GasAdjustment
+		if adjustment <= 0 {
+			adjustment = c.GasAdjustment
+		}
+		gas = uint64(math.Ceil(float64(used) * adjustment))
+	}
+
+	fees := opts.Fees
+	if fees.Empty() {
+		prices := opts.GasPrices
+		if prices.Empty() {
+			prices = c.GasPrices
+		}
+		fees = feesFor(prices, gas)
+	}
+	return gas, fees, nil
+}
+
+// Send builds, signs and broadcasts msgs from key. A transaction rejected in
+// CheckTx is returned together with its *TxError; inclusion in a block can
+// be awaited with WaitForTx.
+func (c *Client) Send(ctx context.Context, key *Key, msgs []sdk.Msg, opts Options) (*Result, error) {
+	if len(msgs) == 0 {
+		return nil, errors.New("nenhuma mensagem para enviar")
+	}
+
+	account, err := c.Account(ctx, key.Address())
+	if err != nil {
+		return nil, err
+	}
+
+	gas, fees, err := c.estimate(ctx, key, account, msgs, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	txBytes, err := buildTx(ctx, key, c.chainID, account, msgs, gas, fees, opts.Memo, true)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.Broadcast(ctx, txBytes)
+	if err != nil {
+		return nil, err
+	}
+	result.Fee = fees.String()
+	return result, result.Err()
+}
@@ -16,6 +16,7 @@ function Dashboard({ nodeStatus: initialStatus, onRefresh, goToStep }) {
   const [error, setError] = useState(null);
   const [showWithdrawModal, setShowWithdrawModal] = useState(false);
   const [showRestakeModal, setShowRestakeModal] = useState(false);
+  const [keyringMigration, setKeyringMigration] = useState(null);
   const logsEndRef = useRef(null);
 
   useEffect(() => {
@@ -111,6 +112,19 @@ function Dashboard({ nodeStatus: initialStatus, onRefresh, goToStep }) {
     }
   };
 
+  const handleMigrateKeyring = async () => {
+    setIsLoading(prev => ({ ...prev, keyring: true }));
+    setError(null);
+    try {
+      setKeyringMigration(await api.migrateTestKeyring());
+      await loadData();
+    } catch (err) {
+      setError(err.message);
+    } finally {
+      setIsLoading(prev => ({ ...prev, keyring: false }));
+    }
+  };
+
   // Check what's missing in the setup
   const hasWallet = status?.hasWallet;
   const isNodeInitialized = status?.isNodeInitialized;
@@ -188,6 +202,37 @@ function Dashboard({ nodeStatus: initialStatus, onRefresh, goToStep }) {
               </div>
             </div>
           )}
+
+          {/* Unencrypted keys left by older versions */}
+          {status?.testKeyringPending && (
+            <div className="bg-yellow-500/20 border border-yellow-500/50 rounded-xl p-4">
+              <div className="flex items-center justify-between">
+                <div className="flex items-center gap-3">
+                  <Lock className="w-5 h-5 text-yellow-400 flex-shrink-0" />
+                  <div>
+                    <h3 className="text-yellow-400 font-semibold">Chaves sem Criptografia</h3>
+                    <p className="text-yellow-400/80 text-sm">
+                      O keyring de teste guarda chaves sem criptografia. Exporte-as com sua senha e apague o keyring.
+                    </p>
+                  </div>
+                </div>
+                <button
+                  onClick={handleMigrateKeyring}
+                  disabled={isLoading.keyring}
+                  className="flex items-center gap-1 px-4 py-2 bg-yellow-500 hover:bg-yellow-600 disabled:opacity-50 text-white text-sm font-medium rounded-lg transition"
+                >
+                  {isLoading.keyring ? 'Migrando...' : 'Migrar'}
+                </button>
+              </div>
+            </div>
+          )}
+
+          {keyringMigration?.wiped && (
+            <div className="bg-green-500/20 border border-green-500/50 rounded-xl p-4 text-green-400 text-sm">
+              Keyring de teste apagado: {keyringMigration.dropped.length} chave(s) das carteiras descartada(s)
+              {keyringMigration.exported.length > 0 && `, exportada(s) para ${keyringMigration.exported.join(', ')}`}.
+            </div>
+          )}
         </div>
 
         {/* Status Cards */}
@@ -12,7 +12,7 @@ const DEPENDENCIES = [
     name: 'Tickfy Blockchain',
     description: 'Binário principal do node',
     size: 123, // MB
-    install: () => api.installDependency('binary'),
+    install: (onProgress) => api.installDependency('binary', onProgress),
     checkInstalled: (status) => status?.isNodeInstalled,
   },
   {
@@ -20,7 +20,7 @@ const DEPENDENCIES = [
     name: 'Cosmovisor',
     description: 'Gerenciador de upgrades automáticos',
     size: 15, // MB
-    install: () => api.installDependency('cosmovisor'),
+    install: (onProgress) => api.installDependency('cosmovisor', onProgress),
     checkInstalled: (status) => status?.isCosmovisorInstalled,
   },
   // Adicione mais dependências aqui conforme necessário
@@ -95,7 +95,9 @@ function DependencySetup({ onComplete }) {
       setCurrentDep(dep.id);
       
       try {
-        await dep.install();
+        await dep.install((percent) => {
+          setProgress(Math.round(((i + percent / 100) / totalDeps) * 100));
+        });
         setInstalledDeps(prev => ({ ...prev, [dep.id]: true }));
         setProgress(Math.round(((i + 1) / totalDeps) * 100));
       } catch (err) {
@@ -1,6 +1,7 @@
 import React, { useState, useEffect } from 'react';
 import { Wallet, Server, Shield, Check, ChevronRight, RefreshCw, AlertTriangle, Trash2, Info, Percent, Coins } from 'lucide-react';
 import { api } from '../lib/api';
+import { toBaseUnits, percentToRate } from '../lib/units';
 import ConfirmModal from './ConfirmModal';
 
 // Random moniker generator
@@ -36,6 +37,9 @@ function NodeSetupWizard({ nodeStatus, onComplete, onRefresh }) {
   // Validator state
   const [commissionPreset, setCommissionPreset] = useState('10'); // '5', '10', '15', '20', 'custom'
   const [customCommission, setCustomCommission] = useState('');
+  // Limits fixed at creation, the chain never lets them change
+  const [maxRate, setMaxRate] = useState('20');
+  const [maxChangeRate, setMaxChangeRate] = useState('1');
   const [stakeAmount, setStakeAmount] = useState('200000');
   const [details, setDetails] = useState('');
   const [website, setWebsite] = useState('');
@@ -165,13 +169,39 @@ function NodeSetupWizard({ nodeStatus, onComplete, onRefresh }) {
   const handleCreateValidator = async () => {
     // Get the commission rate
     const rate = parseFloat(getCommissionRate());
+    const max = parseFloat(maxRate);
+    const maxChange = parseFloat(maxChangeRate);
     const stake = parseFloat(stakeAmount);
 
-    if (commissionPreset === 'custom') {
-      if (isNaN(rate) || rate < 0 || rate > 20) {
-        setError('Taxa de comissão customizada deve ser entre 0% e 20%');
-        return;
-      }
+    if (isNaN(max) || max <= 0 || max > 100) {
+      setError('Comissão máxima deve ser entre 0% e 100%');
+      return;
+    }
+
+    if (isNaN(rate) || rate < 0 || rate > max) {
+      setError(`Taxa de comissão deve ser entre 0% e a comissão máxima (${maxRate}%)`);
+      return;
+    }
+
+    if (isNaN(maxChange) || maxChange <= 0 || maxChange > max) {
+      setError(`Variação máxima diária deve ser maior que 0% e no máximo a comissão máxima (${maxRate}%)`);
+      return;
+    }
+
+    // Convert percentages to decimal
+    const commission = percentToRate(getCommissionRate());
+    const commissionMaxRate = percentToRate(maxRate);
+    const commissionMaxChangeRate = percentToRate(maxChangeRate);
+    if (!commission || !commissionMaxRate || !commissionMaxChangeRate) {
+      setError('Use apenas números decimais nas taxas de comissão');
+      return;
+    }
+
+    // The server takes the amount in base units (1 TKFY = 1,000,000)
+    const stakeUnits = toBaseUnits(stakeAmount);
+    if (stakeUnits === null) {
+      setError('Quantidade de stake inválida, use no máximo 6 casas decimais');
+      return;
     }
 
     if (isNaN(stake) || stake < 200000) {
@@ -183,9 +213,10 @@ function NodeSetupWizard({ nodeStatus, onComplete, onRefresh }) {
     setError(null);
 
     try {
-      // Convert percentages to decimal
-      const commission = (rate / 100).toString();
-      await api.createValidator(moniker, commission, stakeAmount);
+      await api.createValidator(moniker, commission, stakeUnits, undefined, {
+        commissionMaxRate,
+        commissionMaxChangeRate,
+      });
       onRefresh();
       onComplete();
     } catch (err) {
@@ -499,7 +530,7 @@ function NodeSetupWizard({ nodeStatus, onComplete, onRefresh }) {
                     <div className="flex items-center justify-between">
                       <div className="text-left">
                         <div className="text-white font-semibold">Personalizado</div>
-                        <div className="text-xs text-gray-400">Defina sua própria taxa (máx. 20%)</div>
+                        <div className="text-xs text-gray-400">Defina sua própria taxa (máx. {maxRate}%)</div>
                       </div>
                       {commissionPreset === 'custom' && (
                         <Check className="w-5 h-5 text-tickfy-400" />
@@ -516,7 +547,7 @@ function NodeSetupWizard({ nodeStatus, onComplete, onRefresh }) {
                           value={customCommission}
                           onChange={(e) => setCustomCommission(e.target.value)}
                           min="0"
-                          max="20"
+                          max={maxRate}
                           step="0.1"
                           placeholder="Ex: 8"
                           className="flex-1 px-4 py-2 bg-gray-800 border border-gray-700 rounded-lg text-white focus:border-tickfy-500 focus:outline-none"
@@ -524,10 +555,47 @@ function NodeSetupWizard({ nodeStatus, onComplete, onRefresh }) {
                         <span className="text-gray-400 font-medium">%</span>
                       </div>
                       <p className="text-gray-500 text-xs mt-1">
-                        Digite um valor entre 0% e 20%
+                        Digite um valor entre 0% e {maxRate}%
                       </p>
                     </div>
                   )}
+
+                  {/* Commission limits */}
+                  <div className="grid grid-cols-2 gap-3 mt-4">
+                    <div>
+                      <label className="text-gray-400 text-sm mb-1 block">Comissão máxima</label>
+                      <div className="flex items-center gap-2">
+                        <input
+                          type="number"
+                          value={maxRate}
+                          onChange={(e) => setMaxRate(e.target.value)}
+                          min="0"
+                          max="100"
+                          step="0.1"
+                          className="flex-1 min-w-0 px-4 py-2 bg-gray-800 border border-gray-700 rounded-lg text-white focus:border-tickfy-500 focus:outline-none"
+                        />
+                        <span className="text-gray-400 font-medium">%</span>
+                      </div>
+                    </div>
+                    <div>
+                      <label className="text-gray-400 text-sm mb-1 block">Variação máxima diária</label>
+                      <div className="flex items-center gap-2">
+                        <input
+                          type="number"
+                          value={maxChangeRate}
+                          onChange={(e) => setMaxChangeRate(e.target.value)}
+                          min="0"
+                          max={maxRate}
+                          step="0.1"
+                          className="flex-1 min-w-0 px-4 py-2 bg-gray-800 border border-gray-700 rounded-lg text-white focus:border-tickfy-500 focus:outline-none"
+                        />
+                        <span className="text-gray-400 font-medium">%</span>
+                      </div>
+                    </div>
+                  </div>
+                  <p className="text-gray-500 text-xs mt-1">
+                    Estes limites não podem ser alterados depois que o validador é criado
+                  </p>
                 </div>
 
                 {/* Stake Settings */}
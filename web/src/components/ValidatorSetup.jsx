@@ -1,6 +1,7 @@
 import React, { useState, useEffect } from 'react';
 import { Shield, Coins, Percent, AlertTriangle, Loader2, ArrowLeft, Info, Copy, Check } from 'lucide-react';
 import { api } from '../lib/api';
+import { toBaseUnits, percentToRate } from '../lib/units';
 
 function ValidatorSetup({ onComplete, onBack }) {
   const [isLoading, setIsLoading] = useState(false);
@@ -12,6 +13,9 @@ function ValidatorSetup({ onComplete, onBack }) {
   const [formData, setFormData] = useState({
     commission: '0.10',
     stakeAmount: '',
+    // Limites em %, fixos depois que o validador é criado
+    commissionMaxRate: '20',
+    commissionMaxChangeRate: '1',
   });
 
   const MIN_STAKE = 200000;
@@ -45,8 +49,16 @@ function ValidatorSetup({ onComplete, onBack }) {
   };
 
   const handleCreateValidator = async () => {
-    const stakeAmount = parseInt(formData.stakeAmount);
-    
+    const stakeAmount = parseFloat(formData.stakeAmount);
+    const stakeUnits = toBaseUnits(formData.stakeAmount);
+    const maxRate = percentToRate(formData.commissionMaxRate);
+    const maxChangeRate = percentToRate(formData.commissionMaxChangeRate);
+
+    if (stakeUnits === null) {
+      setError('Quantidade de stake inválida, use no máximo 6 casas decimais');
+      return;
+    }
+
     if (!stakeAmount || stakeAmount < MIN_STAKE) {
       setError(`Stake mínimo é ${MIN_STAKE.toLocaleString()} TKFY`);
       return;
@@ -57,6 +69,21 @@ function ValidatorSetup({ onComplete, onBack }) {
       return;
     }
 
+    if (!maxRate || parseFloat(maxRate) <= 0 || parseFloat(maxRate) > 1) {
+      setError('Comissão máxima deve ser entre 0% e 100%');
+      return;
+    }
+
+    if (parseFloat(formData.commission) > parseFloat(maxRate)) {
+      setError(`A comissão não pode exceder a comissão máxima (${formData.commissionMaxRate}%)`);
+      return;
+    }
+
+    if (!maxChangeRate || parseFloat(maxChangeRate) <= 0 || parseFloat(maxChangeRate) > parseFloat(maxRate)) {
+      setError(`Variação máxima diária deve ser maior que 0% e no máximo a comissão máxima (${formData.commissionMaxRate}%)`);
+      return;
+    }
+
     setIsLoading(true);
     setError(null);
 
@@ -65,7 +92,9 @@ function ValidatorSetup({ onComplete, onBack }) {
       await api.createValidator(
         status.moniker || 'Validator',
         formData.commission,
-        (stakeAmount * 1000000).toString()
+        stakeUnits,
+        undefined,
+        { commissionMaxRate: maxRate, commissionMaxChangeRate: maxChangeRate }
       );
       onComplete();
     } catch (err) {
@@ -196,6 +225,45 @@ function ValidatorSetup({ onComplete, onBack }) {
             </p>
           </div>
 
+          {/* Commission limits */}
+          <div className="grid grid-cols-2 gap-3">
+            <div>
+              <label className="text-gray-400 text-sm mb-1 block">Comissão máxima</label>
+              <div className="relative">
+                <input
+                  type="number"
+                  name="commissionMaxRate"
+                  value={formData.commissionMaxRate}
+                  onChange={handleInputChange}
+                  min="0"
+                  max="100"
+                  step="0.1"
+                  className="w-full px-4 py-3 bg-gray-800 border border-gray-700 rounded-lg text-white focus:border-tickfy-500 focus:outline-none pr-10"
+                />
+                <span className="absolute right-4 top-1/2 -translate-y-1/2 text-gray-500">%</span>
+              </div>
+            </div>
+            <div>
+              <label className="text-gray-400 text-sm mb-1 block">Variação máxima diária</label>
+              <div className="relative">
+                <input
+                  type="number"
+                  name="commissionMaxChangeRate"
+                  value={formData.commissionMaxChangeRate}
+                  onChange={handleInputChange}
+                  min="0"
+                  max={formData.commissionMaxRate}
+                  step="0.1"
+                  className="w-full px-4 py-3 bg-gray-800 border border-gray-700 rounded-lg text-white focus:border-tickfy-500 focus:outline-none pr-10"
+                />
+                <span className="absolute right-4 top-1/2 -translate-y-1/2 text-gray-500">%</span>
+              </div>
+            </div>
+          </div>
+          <p className="text-xs text-gray-500 -mt-2">
+            Estes limites não podem ser alterados depois que o validador é criado
+          </p>
+
           {error && (
             <div className="bg-red-500/20 border border-red-500/50 rounded-lg p-3 text-red-400 text-sm">
               {error}
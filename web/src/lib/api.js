@@ -66,8 +66,17 @@ class ApiClient {
     return this.request('GET', '/dependencies/status');
   }
 
-  async installDependency(component) {
-    return this.request('POST', '/dependencies/install', { component });
+  // Starts the install job and polls it until it finishes, reporting the
+  // download percent to onProgress
+  async installDependency(component, onProgress) {
+    const job = await this.request('POST', '/dependencies/install', { component });
+    for (;;) {
+      const info = await this.request('GET', `/dependencies/jobs/${job.id}`);
+      if (onProgress) onProgress(info.progress);
+      if (info.status === 'succeeded') return info;
+      if (info.status !== 'running') throw new Error(info.error || 'Falha na instalação');
+      await new Promise(resolve => setTimeout(resolve, 1000));
+    }
   }
 
   async setup(password) {
@@ -118,10 +127,41 @@ class ApiClient {
     return this.request('POST', '/wallet/delete', { walletId });
   }
 
+  async reencryptWallets() {
+    return this.request('POST', '/wallet/reencrypt', { password: this.getPassword() });
+  }
+
+  async migrateTestKeyring() {
+    return this.request('POST', '/wallet/migrate-keyring', { password: this.getPassword() });
+  }
+
   async getBalance() {
     return this.request('GET', '/wallet/balance');
   }
 
+  // Send - first call returns a confirmation with the estimated fee,
+  // confirmSend executes it
+  async prepareSend(to, amount, memo, walletId, denom) {
+    return this.request('POST', '/wallet/send', { to, amount, memo, denom, walletId, password: this.getPassword() });
+  }
+
+  async confirmSend(confirmationId) {
+    return this.request('POST', '/wallet/send', { confirmationId, password: this.getPassword() });
+  }
+
+  // Address book
+  async getAddressBook() {
+    return this.request('GET', '/addressbook');
+  }
+
+  async saveAddressBookEntry(label, address, id) {
+    return this.request('POST', '/addressbook', { id, label, address });
+  }
+
+  async deleteAddressBookEntry(id) {
+    return this.request('POST', '/addressbook/delete', { id });
+  }
+
   // Node
   async getNodeStatus() {
     return this.request('GET', '/node/status');
@@ -143,8 +183,41 @@ class ApiClient {
     return this.request('POST', '/node/stop');
   }
 
-  async getLogs() {
-    return this.request('GET', '/node/logs');
+  // filters: { query, level, module, since, until, cursor, limit }
+  async getLogs(filters = {}) {
+    const params = new URLSearchParams();
+    Object.entries(filters).forEach(([key, value]) => {
+      if (value !== undefined && value !== null && value !== '') {
+        params.set(key, value);
+      }
+    });
+    const qs = params.toString();
+    return this.request('GET', `/node/logs${qs ? `?${qs}` : ''}`);
+  }
+
+  // filters: { query, level, module, after }; reconexões usam Last-Event-ID
+  streamLogs(filters = {}) {
+    const params = new URLSearchParams({ token: this.token || '' });
+    Object.entries(filters).forEach(([key, value]) => {
+      if (value !== undefined && value !== null && value !== '') {
+        params.set(key, value);
+      }
+    });
+    return new EventSource(`${API_URL}/node/logs/stream?${params.toString()}`);
+  }
+
+  async getCrashHistory() {
+    return this.request('GET', '/node/crashes');
+  }
+
+  // Modo de execução: mode "process" ou "systemd", systemdUnit, systemdScope
+  // "system" ou "user" e os limites de reinício; campos omitidos são mantidos
+  async getRuntimeConfig() {
+    return this.request('GET', '/node/runtime');
+  }
+
+  async saveRuntimeConfig(config) {
+    return this.request('POST', '/node/runtime', config);
   }
 
   // Cosmovisor
@@ -156,12 +229,77 @@ class ApiClient {
     return this.request('POST', '/cosmovisor/setup');
   }
 
+  // Upgrade agendado on-chain: nome, altura, ETA e binário preparado
+  async getUpgradeStatus() {
+    return this.request('GET', '/upgrade');
+  }
+
+  // Retorna a tarefa; acompanhe por getJob/streamJob
+  async prepareUpgrade() {
+    return this.request('POST', '/upgrade/prepare');
+  }
+
+  // Versões do binário
+  async getVersions() {
+    return this.request('GET', '/versions');
+  }
+
+  // Sem versão instala a mais recente; retorna a tarefa
+  async installVersion(version = '') {
+    return this.request('POST', '/versions/install', { version });
+  }
+
+  async activateVersion(version) {
+    return this.request('POST', '/versions/activate', { version });
+  }
+
+  async rollbackVersion() {
+    return this.request('POST', '/versions/rollback');
+  }
+
+  async setReleaseIndex(url) {
+    return this.request('POST', '/versions/index', { url });
+  }
+
+  // Verificação de downloads: chave de assinatura, hashes do genesis e quarentena
+  async getVerification() {
+    return this.request('GET', '/verification');
+  }
+
+  // signingKey: chave ed25519 em base64; genesisHashes: { chainId: sha256 };
+  // cosmovisorHashes: { nomeDoTarball: sha256 }
+  async saveVerification(signingKey, genesisHashes = {}, cosmovisorHashes = {}) {
+    return this.request('POST', '/verification', { signingKey, genesisHashes, cosmovisorHashes });
+  }
+
+  // Jobs - instalações rodam em segundo plano
+  async getJobs() {
+    return this.request('GET', '/jobs');
+  }
+
+  async getJob(id) {
+    return this.request('GET', `/jobs/${id}`);
+  }
+
+  async cancelJob(id) {
+    return this.request('POST', `/jobs/${id}/cancel`);
+  }
+
+  // EventSource não envia headers, então o token vai na query string
+  streamJob(id) {
+    return new EventSource(`${API_URL}/jobs/${id}/events?token=${encodeURIComponent(this.token)}`);
+  }
+
   // Validator - usa senha do dashboard automaticamente
-  async createValidator(moniker, commission, stakeAmount) {
+  // options: { commissionMaxRate, commissionMaxChangeRate, denom, minSelfDelegation,
+  //            identity, website, securityContact, details }
+  async createValidator(moniker, commission, stakeAmount, walletId, options = {}) {
     return this.request('POST', '/validator/create', {
+      ...options,
       moniker,
       commission,
       stakeAmount,
+      walletId,
       password: this.getPassword(),
     });
   }
@@ -174,12 +312,111 @@ class ApiClient {
     return this.request('GET', '/validator/staking');
   }
 
-  async withdrawRewards() {
-    return this.request('POST', '/validator/withdraw', { password: this.getPassword() });
+  async withdrawRewards(walletId) {
+    return this.request('POST', '/validator/withdraw', { walletId, password: this.getPassword() });
+  }
+
+  async restake(walletId, feeReserve) {
+    return this.request('POST', '/validator/restake', { walletId, feeReserve, password: this.getPassword() });
+  }
+
+  // changes: { moniker, identity, website, securityContact, details, commissionRate }
+  async editValidator(changes, walletId) {
+    return this.request('POST', '/validator/edit', { ...changes, walletId, password: this.getPassword() });
+  }
+
+  async unjail(walletId) {
+    return this.request('POST', '/validator/unjail', { walletId, password: this.getPassword() });
+  }
+
+  // Delegations - amounts in base units of the bond denom
+  async getValidators() {
+    return this.request('GET', '/validators');
+  }
+
+  async getDelegations() {
+    return this.request('GET', '/delegations');
+  }
+
+  async delegate(validator, amount, walletId) {
+    return this.request('POST', '/delegations/delegate', { validator, amount, walletId, password: this.getPassword() });
+  }
+
+  async undelegate(validator, amount, walletId) {
+    return this.request('POST', '/delegations/undelegate', { validator, amount, walletId, password: this.getPassword() });
+  }
+
+  async redelegate(validator, dstValidator, amount, walletId) {
+    return this.request('POST', '/delegations/redelegate', { validator, dstValidator, amount, walletId, password: this.getPassword() });
+  }
+
+  // Transactions - filters: { status, type, wallet, page, limit }
+  async getTxHistory(filters = {}) {
+    const params = new URLSearchParams();
+    Object.entries(filters).forEach(([key, value]) => {
+      if (value !== undefined && value !== null && value !== '') {
+        params.set(key, value);
+      }
+    });
+    const qs = params.toString();
+    return this.request('GET', `/txs${qs ? `?${qs}` : ''}`);
+  }
+
+  async importTxHistory() {
+    return this.request('POST', '/txs/import');
+  }
+
+  // Governance
+  async getProposals(activeOnly = false, limit) {
+    const params = new URLSearchParams();
+    if (activeOnly) params.set('status', 'active');
+    if (limit) params.set('limit', limit);
+    const qs = params.toString();
+    return this.request('GET', `/gov/proposals${qs ? `?${qs}` : ''}`);
+  }
+
+  async getProposal(id) {
+    return this.request('GET', `/gov/proposals/${id}`);
+  }
+
+  // option: yes | no | abstain | no_with_veto
+  async vote(id, option, walletId) {
+    return this.request('POST', `/gov/proposals/${id}/vote`, { option, walletId, password: this.getPassword() });
+  }
+
+  // options: [{ option, weight }] with weights adding up to 1
+  async voteWeighted(id, options, walletId) {
+    return this.request('POST', `/gov/proposals/${id}/vote`, { options, walletId, password: this.getPassword() });
+  }
+
+  // Auto-compound
+  async getAutoCompound() {
+    return this.request('GET', '/autocompound');
+  }
+
+  async saveAutoCompoundConfig(config) {
+    return this.request('POST', '/autocompound/config', config);
+  }
+
+  async authorizeAutoCompound(feeBudget, grantDays, walletId) {
+    return this.request('POST', '/autocompound/authorize', {
+      feeBudget,
+      grantDays,
+      walletId,
+      password: this.getPassword(),
+    });
+  }
+
+  async revokeAutoCompound(walletId) {
+    return this.request('POST', '/autocompound/revoke', { walletId, password: this.getPassword() });
+  }
+
+  async runAutoCompound() {
+    return this.request('POST', '/autocompound/run');
   }
 
-  async restake() {
-    return this.request('POST', '/validator/restake', { password: this.getPassword() });
+  async getAutoCompoundHistory() {
+    return this.request('GET', '/autocompound/history');
   }
 }
 
@@ -0,0 +1,27 @@
+// Conversões de valores digitados pelo usuário, feitas sobre a string decimal
+// para não passar por ponto flutuante
+
+const DECIMAL_RE = /^(\d+)(?:\.(\d*))?$/;
+
+// Converte um valor em TKFY, como "200000.5", para unidades base
+// (1 TKFY = 1,000,000). Retorna null se o valor não for um decimal válido ou
+// tiver mais casas do que a denominação.
+export function toBaseUnits(amount, decimals = 6) {
+  const match = DECIMAL_RE.exec(String(amount).trim());
+  if (!match) return null;
+  const [, whole, fraction = ''] = match;
+  if (fraction.length > decimals) return null;
+  return (whole + fraction.padEnd(decimals, '0')).replace(/^0+(?=\d)/, '');
+}
+
+// Converte um percentual, como "8.5", na fração que a rede espera ("0.085").
+// Retorna null se o valor não for um decimal válido.
+export function percentToRate(percent) {
+  const match = DECIMAL_RE.exec(String(percent).trim());
+  if (!match) return null;
+  const [, whole, fraction = ''] = match;
+  if (fraction.length > 16) return null;
+  const digits = whole.padStart(3, '0');
+  const intPart = digits.slice(0, -2).replace(/^0+(?=\d)/, '');
+  return `${intPart}.${digits.slice(-2)}${fraction}`;
+}